@@ -0,0 +1,78 @@
+package jaywt
+
+import (
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+)
+
+// GetWithACR extracts and validates the JWT token from the request, then
+// checks it asserts at least the supplied authentication context. Either the
+// 'acr' claim has to meet it, or the 'amr' claim has to list it. Otherwise,
+// it fails with ErrInsufficientACR.
+//
+// The 'acr' claim meets the requirement if it's at least as strong according
+// to Options.ACRLevels, or if it's equal when the levels aren't configured.
+func (m *Core) GetWithACR(r *http.Request, minACR string) (*jwt.Token, error) {
+	return m.getVerified(r, jwt.MapClaims{}, ValidatorFunc(func(token *jwt.Token, _ *http.Request) error {
+		if !m.meetsACR(token.Claims.(jwt.MapClaims), minACR) {
+			return ErrInsufficientACR
+		}
+
+		return nil
+	}))
+}
+
+// RequireACR returns a middleware that works like Handler, but checks the
+// token using GetWithACR. Errors go to Options.ErrorHandler, whose default
+// rejects tokens with an insufficient authentication context with 403
+// Forbidden.
+func (m *Core) RequireACR(minACR string, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := m.GetWithACR(r, minACR)
+		if err != nil {
+			m.Options.ErrorHandler(w, r, err)
+			return
+		}
+
+		m.serve(w, r, next, token)
+	})
+}
+
+// Helper functions
+// ---
+
+func (m *Core) meetsACR(claims jwt.MapClaims, minACR string) bool {
+	if acr, ok := claims["acr"].(string); ok {
+		if levels := m.Options.ACRLevels; levels != nil {
+			if have, want := indexOf(levels, acr), indexOf(levels, minACR); have >= 0 && want >= 0 && have >= want {
+				return true
+			}
+		} else if acr == minACR {
+			return true
+		}
+	}
+
+	amr, _ := claims["amr"].([]interface{})
+	for _, method := range amr {
+		if method == minACR {
+			return true
+		}
+	}
+
+	return false
+}
+
+func indexOf(values []string, value string) int {
+	for i, v := range values {
+		if v == value {
+			return i
+		}
+	}
+
+	return -1
+}
@@ -0,0 +1,101 @@
+package jaywt_test
+
+import (
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var acrLevels = []string{"pwd", "mfa", "hwk"}
+
+var acrTable = []struct {
+	claims jwt.MapClaims
+	levels []string
+	status int
+}{
+	{jwt.MapClaims{"acr": "mfa"}, nil, http.StatusOK},
+	{jwt.MapClaims{"acr": "hwk"}, nil, http.StatusForbidden},
+	{jwt.MapClaims{"acr": "hwk"}, acrLevels, http.StatusOK},
+	{jwt.MapClaims{"acr": "pwd"}, acrLevels, http.StatusForbidden},
+	{jwt.MapClaims{"acr": "unknown"}, acrLevels, http.StatusForbidden},
+	{jwt.MapClaims{"amr": []string{"pwd", "mfa"}}, nil, http.StatusOK},
+	{jwt.MapClaims{"amr": []string{"pwd"}}, nil, http.StatusForbidden},
+	{jwt.MapClaims{"sub": sampleSubject}, acrLevels, http.StatusForbidden},
+}
+
+func TestRequireACR(t *testing.T) {
+	for _, c := range acrTable {
+		req := sampleRequest(t, jwt.SigningMethodHS256, c.claims)
+		p := jaywt.New(&jaywt.Options{
+			Keyfunc:   sampleKeyfunc,
+			ACRLevels: c.levels,
+		})
+
+		rec := httptest.NewRecorder()
+		p.RequireACR("mfa", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+
+		if rec.Code != c.status {
+			t.Errorf("%v: Status is %d, want %d", c.claims, rec.Code, c.status)
+		}
+	}
+}
+
+func TestRequireACRUnauthorized(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	rec := httptest.NewRecorder()
+	p.RequireACR("mfa", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Next handler should not be called")
+	})).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Status is %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+func TestRequireACRErrorHandler(t *testing.T) {
+	var handled error
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			handled = err
+			http.Error(w, err.Error(), http.StatusTeapot)
+		},
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"acr": "pwd"})
+	rec := httptest.NewRecorder()
+	p.RequireACR("mfa", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Next handler should not be called")
+	})).ServeHTTP(rec, req)
+
+	if handled != jaywt.ErrInsufficientACR {
+		t.Errorf("Got %v, want %v", handled, jaywt.ErrInsufficientACR)
+	}
+
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("Status is %d, want %d", rec.Code, http.StatusTeapot)
+	}
+}
+
+func TestGetWithACR(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:   sampleKeyfunc,
+		ACRLevels: acrLevels,
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"acr": "hwk"})
+	if _, err := p.GetWithACR(req, "mfa"); err != nil {
+		t.Error(err)
+	}
+
+	req = sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"acr": "pwd"})
+	if _, err := p.GetWithACR(req, "mfa"); err != jaywt.ErrInsufficientACR {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrInsufficientACR)
+	}
+}
@@ -0,0 +1,65 @@
+package jaywt
+
+import (
+	"crypto/subtle"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+)
+
+// GetWithAssertion extracts and validates the JWT token from the request,
+// then verifies the request's 'Assertion' header, a JWS signed by the key in
+// the token's 'cnf.jwk' claim whose 'challenge' claim is the base64url
+// encoded challenge the server issued, like a WebAuthn assertion. Tokens
+// without a public 'cnf.jwk' fail with ErrConfirmationKey, assertions that
+// aren't signed by it with ErrAssertionInvalid, and assertions over another
+// challenge with ErrAssertionChallenge. An empty challenge never matches.
+// It returns the parsed token, if successful.
+func (m *Core) GetWithAssertion(r *http.Request, challenge []byte) (*jwt.Token, error) {
+	return m.getVerified(r, jwt.MapClaims{}, ValidatorFunc(func(token *jwt.Token, r *http.Request) error {
+		return checkAssertion(token, r, challenge)
+	}))
+}
+
+// Helper functions
+// ---
+
+// checkAssertion checks the request's assertion is signed by the token's
+// confirmation key, over the challenge.
+func checkAssertion(token *jwt.Token, r *http.Request, challenge []byte) error {
+	claims, err := claimsMap(token)
+	if err != nil {
+		return err
+	}
+
+	cnf, _ := claims["cnf"].(map[string]interface{})
+	if cnf["jwk"] == nil {
+		return ErrConfirmationKey
+	}
+
+	_, key, err := publicJWK(cnf["jwk"])
+	if err != nil {
+		return ErrConfirmationKey
+	}
+
+	// Verify assertion signature
+	assertions := r.Header[http.CanonicalHeaderKey("Assertion")]
+	if len(assertions) != 1 || assertions[0] == "" {
+		return ErrAssertionInvalid
+	}
+
+	parser := &jwt.Parser{ValidMethods: dpopMethods}
+	assertion, err := parser.Parse(assertions[0], func(*jwt.Token) (interface{}, error) {
+		return key, nil
+	})
+	if err != nil {
+		return ErrAssertionInvalid
+	}
+
+	// Verify challenge
+	got, _ := assertion.Claims.(jwt.MapClaims)["challenge"].(string)
+	if len(challenge) == 0 || subtle.ConstantTimeCompare([]byte(got), []byte(jwt.EncodeSegment(challenge))) != 1 {
+		return ErrAssertionChallenge
+	}
+
+	return nil
+}
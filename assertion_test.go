@@ -0,0 +1,99 @@
+package jaywt_test
+
+import (
+	"crypto/ecdsa"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"testing"
+)
+
+var sampleChallenge = []byte("server-challenge-1234")
+
+func TestGetWithAssertionOk(t *testing.T) {
+	key := sampleECKey(t)
+	req := assertionRequest(t, jwt.MapClaims{"jwk": ecJWK(&key.PublicKey)}, key, sampleChallenge)
+
+	if _, err := newAssertionCore().GetWithAssertion(req, sampleChallenge); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetWithAssertionConfirmationKey(t *testing.T) {
+	key := sampleECKey(t)
+	table := []jwt.MapClaims{
+		nil,
+		{"jkt": "thumbprint"},
+		{"jwk": map[string]string{"kty": "oct", "k": "c2VjcmV0"}},
+		{"jwk": "not a key"},
+	}
+
+	for _, cnf := range table {
+		req := assertionRequest(t, cnf, key, sampleChallenge)
+
+		if _, err := newAssertionCore().GetWithAssertion(req, sampleChallenge); err != jaywt.ErrConfirmationKey {
+			t.Errorf("%v: Got %v, want %v", cnf, err, jaywt.ErrConfirmationKey)
+		}
+	}
+}
+
+func TestGetWithAssertionInvalid(t *testing.T) {
+	key := sampleECKey(t)
+	other := sampleECKey(t)
+
+	// Signed by another key
+	req := assertionRequest(t, jwt.MapClaims{"jwk": ecJWK(&key.PublicKey)}, other, sampleChallenge)
+	if _, err := newAssertionCore().GetWithAssertion(req, sampleChallenge); err != jaywt.ErrAssertionInvalid {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrAssertionInvalid)
+	}
+
+	// Missing
+	req = assertionRequest(t, jwt.MapClaims{"jwk": ecJWK(&key.PublicKey)}, key, sampleChallenge)
+	req.Header.Del("Assertion")
+	if _, err := newAssertionCore().GetWithAssertion(req, sampleChallenge); err != jaywt.ErrAssertionInvalid {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrAssertionInvalid)
+	}
+}
+
+func TestGetWithAssertionChallenge(t *testing.T) {
+	key := sampleECKey(t)
+	req := assertionRequest(t, jwt.MapClaims{"jwk": ecJWK(&key.PublicKey)}, key, []byte("stale-challenge"))
+
+	if _, err := newAssertionCore().GetWithAssertion(req, sampleChallenge); err != jaywt.ErrAssertionChallenge {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrAssertionChallenge)
+	}
+
+	if _, err := newAssertionCore().GetWithAssertion(req, nil); err != jaywt.ErrAssertionChallenge {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrAssertionChallenge)
+	}
+}
+
+// Helper functions
+// ---
+
+func newAssertionCore() *jaywt.Core {
+	return jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+}
+
+// assertionRequest makes a request with a token confirmed by cnf, and an
+// assertion over the challenge signed by the key.
+func assertionRequest(t *testing.T, cnf jwt.MapClaims, key *ecdsa.PrivateKey, challenge []byte) *http.Request {
+	claims := jwt.MapClaims{"sub": sampleSubject}
+	if cnf != nil {
+		claims["cnf"] = cnf
+	}
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, claims)
+
+	assertion, err := jwt.NewWithClaims(jwt.SigningMethodES256, jwt.MapClaims{
+		"challenge": jwt.EncodeSegment(challenge),
+	}).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req.Header.Set("Assertion", assertion)
+	return req
+}
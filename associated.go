@@ -0,0 +1,62 @@
+package jaywt
+
+import (
+	"fmt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+)
+
+// Helper functions
+// ---
+
+// associatedMethod verifies signatures over the signing input followed by
+// the associated data of Options.AssociatedData.
+type associatedMethod struct {
+	jwt.SigningMethod
+	data []byte
+}
+
+func (m *associatedMethod) Verify(signingString, signature string, key interface{}) error {
+	if err := m.SigningMethod.Verify(signingString+string(m.data), signature, key); err != nil {
+		return fmt.Errorf("%w: %w", ErrAssociatedDataMismatch, err)
+	}
+
+	return nil
+}
+
+// associatedData returns the data the token must be signed over, if any.
+// Without the request, the data can't be determined, so tokens are rejected.
+func (m *Core) associatedData(r *http.Request) ([]byte, error) {
+	if m.Options.AssociatedData == nil {
+		return nil, nil
+	}
+
+	if r == nil {
+		return nil, fmt.Errorf("%w: no request to bind the token to", ErrAssociatedDataMismatch)
+	}
+
+	data := m.Options.AssociatedData(r)
+	if data == nil {
+		data = []byte{}
+	}
+
+	return data, nil
+}
+
+// bindAssociatedData makes the token's signature verify over the data.
+func bindAssociatedData(token *jwt.Token, data []byte) {
+	if data != nil {
+		token.Method = &associatedMethod{token.Method, data}
+	}
+}
+
+// unbindAssociatedData restores the token's signing method.
+func unbindAssociatedData(token *jwt.Token) {
+	if token == nil {
+		return
+	}
+
+	if m, ok := token.Method.(*associatedMethod); ok {
+		token.Method = m.SigningMethod
+	}
+}
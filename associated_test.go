@@ -0,0 +1,81 @@
+package jaywt_test
+
+import (
+	"errors"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestGetAssociatedData(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:        sampleKeyfunc,
+		AssociatedData: sampleAssociatedData,
+	})
+	raw := associatedToken(t, "GET /orders")
+
+	req := httptest.NewRequest(http.MethodGet, "/orders", nil)
+	req.Header.Set("Authorization", "Bearer "+raw)
+	token, err := p.Get(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if token.Method != jwt.SigningMethodHS256 {
+		t.Errorf("Got %v, want %v", token.Method, jwt.SigningMethodHS256)
+	}
+}
+
+func TestGetAssociatedDataMismatch(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:        sampleKeyfunc,
+		AssociatedData: sampleAssociatedData,
+	})
+	plain := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+
+	table := []struct {
+		method string
+		raw    string
+	}{
+		{http.MethodDelete, associatedToken(t, "GET /orders")},
+		{http.MethodGet, strings.TrimPrefix(plain.Header.Get("Authorization"), "Bearer ")},
+	}
+
+	for _, c := range table {
+		req := httptest.NewRequest(c.method, "/orders", nil)
+		req.Header.Set("Authorization", "Bearer "+c.raw)
+		if _, err := p.Get(req); !errors.Is(err, jaywt.ErrAssociatedDataMismatch) {
+			t.Errorf("%s: Got %v, want %v", c.method, err, jaywt.ErrAssociatedDataMismatch)
+		}
+	}
+
+	if _, err := p.ValidateRaw(associatedToken(t, "GET /orders")); !errors.Is(err, jaywt.ErrAssociatedDataMismatch) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrAssociatedDataMismatch)
+	}
+}
+
+// Helper functions
+// ---
+
+func sampleAssociatedData(r *http.Request) []byte {
+	return []byte(r.Method + " " + r.URL.Path)
+}
+
+// associatedToken signs a token over its signing input followed by the data.
+func associatedToken(t *testing.T, data string) string {
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+	input, err := token.SigningString()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := jwt.SigningMethodHS256.Sign(input+data, []byte(sampleSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return input + "." + sig
+}
@@ -0,0 +1,61 @@
+package jaywt
+
+import (
+	"net/http"
+	"time"
+)
+
+// AuditEvent records a successful authentication, passed to Options.AuditFunc.
+// Fields of claims missing from the token are empty.
+type AuditEvent struct {
+	// When the token was accepted.
+	Time time.Time
+	// The token's 'sub' claim.
+	Subject string
+	// The token's 'iss' claim.
+	Issuer string
+	// The token's 'aud' claim.
+	Audience []string
+	// The token's 'kid' header.
+	KeyID string
+	// The token's 'jti' claim.
+	TokenID string
+	// The token's 'iat' claim.
+	IssuedAt time.Time
+	// Whether the token's signature was verified, see Result.Verified.
+	Verified bool
+	// Network address of the client, see http.Request.RemoteAddr.
+	RemoteAddr string
+	// IP address of the client, see Options.ClientIPFunc. Unlike RemoteAddr,
+	// it's the client behind Options.TrustedProxies.
+	ClientIP string
+}
+
+// Helper functions
+// ---
+
+// auditEvent returns the AuditEvent of the request authenticated by the token.
+func (m *Core) auditEvent(r *http.Request, res *Result) AuditEvent {
+	event := AuditEvent{
+		Time:       time.Now(),
+		Verified:   res.Verified,
+		RemoteAddr: r.RemoteAddr,
+		ClientIP:   m.Options.ClientIPFunc(r),
+	}
+	event.KeyID, _ = res.Token.Header["kid"].(string)
+
+	claims, err := claimsMap(res.Token)
+	if err != nil {
+		return event
+	}
+
+	event.Subject, _ = claims["sub"].(string)
+	event.Issuer, _ = claims["iss"].(string)
+	event.Audience = audiences(claims)
+	event.TokenID, _ = claims["jti"].(string)
+	if iat, ok := numericClaim(claims, "iat"); ok {
+		event.IssuedAt = time.Unix(iat, 0)
+	}
+
+	return event
+}
@@ -0,0 +1,110 @@
+package jaywt_test
+
+import (
+	"errors"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAuditFunc(t *testing.T) {
+	var events []jaywt.AuditEvent
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		AuditFunc: func(event jaywt.AuditEvent) {
+			events = append(events, event)
+		},
+	})
+
+	iat := time.Now().Add(-1 * time.Minute).Unix()
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+		"iss": "https://example.com",
+		"aud": "api",
+		"jti": "token-1",
+		"iat": iat,
+	}, withHeader("kid", sampleKID))
+	if _, err := p.Get(req); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(events) != 1 {
+		t.Fatalf("Got %d events, want 1", len(events))
+	}
+
+	event := events[0]
+	if event.Subject != sampleSubject || event.Issuer != "https://example.com" || event.TokenID != "token-1" {
+		t.Errorf("Got %+v, want the token's claims", event)
+	}
+
+	if len(event.Audience) != 1 || event.Audience[0] != "api" {
+		t.Errorf("Got %v, want [api]", event.Audience)
+	}
+
+	if event.KeyID != sampleKID {
+		t.Errorf("Got %s, want %s", event.KeyID, sampleKID)
+	}
+
+	if event.IssuedAt.Unix() != iat {
+		t.Errorf("Got %d, want %d", event.IssuedAt.Unix(), iat)
+	}
+
+	if !event.Verified || event.RemoteAddr != req.RemoteAddr || event.Time.IsZero() {
+		t.Errorf("Got %+v, want a verified event from %s", event, req.RemoteAddr)
+	}
+
+	if event.ClientIP != "192.0.2.1" {
+		t.Errorf("Got %s, want 192.0.2.1", event.ClientIP)
+	}
+}
+
+func TestAuditFuncFailure(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		AuditFunc: func(event jaywt.AuditEvent) {
+			t.Errorf("Got %+v, want no event", event)
+		},
+	})
+
+	if _, err := p.Get(httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"exp": float64(time.Now().Add(-1 * time.Hour).Unix())})
+	if _, err := p.Get(req); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+func TestAuditFuncRejectedByHelper(t *testing.T) {
+	var events []jaywt.AuditEvent
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:     sampleKeyfunc,
+		ReplayStore: jaywt.NewMemoryReplayStore(),
+		TrackStats:  true,
+		AuditFunc: func(event jaywt.AuditEvent) {
+			events = append(events, event)
+		},
+	})
+	claims := jwt.MapClaims{"sub": sampleSubject, "jti": "token-1", "scope": "read"}
+
+	// Tokens the helper rejects are neither audited nor consumed
+	if _, err := p.GetWithScopes(sampleRequest(t, jwt.SigningMethodHS256, claims), "admin"); !errors.Is(err, jaywt.ErrInsufficientScope) {
+		t.Fatalf("Got %v, want %v", err, jaywt.ErrInsufficientScope)
+	}
+
+	if stats := p.Stats(); len(events) != 0 || stats.Successes != 0 {
+		t.Errorf("Got %d events and %d successes, want none", len(events), stats.Successes)
+	}
+
+	if _, err := p.GetWithScopes(sampleRequest(t, jwt.SigningMethodHS256, claims), "read"); err != nil {
+		t.Fatal(err)
+	}
+
+	if stats := p.Stats(); len(events) != 1 || stats.Successes != 1 {
+		t.Errorf("Got %d events and %d successes, want 1", len(events), stats.Successes)
+	}
+}
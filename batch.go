@@ -0,0 +1,51 @@
+package jaywt
+
+import (
+	"context"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"runtime"
+	"sync"
+)
+
+// ParseRawConcurrent validates the raw token strings like ValidateRaw, using
+// the number of workers, e.g. in batch jobs checking archived tokens. If
+// workers isn't positive, GOMAXPROCS workers are used. The returned tokens
+// and errors are in the order of raws. Once the context is done, the
+// remaining tokens fail with ErrContextCancelled.
+//
+// The Keyfunc and other functions in the Options are called concurrently,
+// so they must be safe for concurrent use.
+func (m *Core) ParseRawConcurrent(ctx context.Context, raws []string, workers int) ([]*jwt.Token, []error) {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	if workers > len(raws) {
+		workers = len(raws)
+	}
+
+	tokens := make([]*jwt.Token, len(raws))
+	errs := make([]error, len(raws))
+	jobs := make(chan int)
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				if errs[i] = contextError(ctx); errs[i] == nil {
+					tokens[i], errs[i] = m.validateRaw(ctx, raws[i])
+				}
+			}
+		}()
+	}
+
+	for i := range raws {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	return tokens, errs
+}
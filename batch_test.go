@@ -0,0 +1,92 @@
+package jaywt_test
+
+import (
+	"context"
+	"errors"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestParseRawConcurrent(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	raws := make([]string, 50)
+	for i := range raws {
+		raws[i] = sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"n": float64(i)})
+		if i%5 == 0 {
+			raws[i] = "asdf"
+		}
+	}
+
+	tokens, errs := p.ParseRawConcurrent(context.Background(), raws, 4)
+	for i := range raws {
+		if i%5 == 0 {
+			if errs[i] == nil || tokens[i] != nil {
+				t.Errorf("%d: Error was expected, got nil", i)
+			}
+
+			continue
+		}
+
+		if errs[i] != nil {
+			t.Errorf("%d: %v", i, errs[i])
+			continue
+		}
+
+		if n := tokens[i].Claims.(jwt.MapClaims)["n"]; n != float64(i) {
+			t.Errorf("Got %v, want %d", n, i)
+		}
+	}
+}
+
+func TestParseRawConcurrentJWKSURL(t *testing.T) {
+	server, hits := sampleJWKSServer(t, http.StatusOK)
+	defer server.Close()
+
+	p := jaywt.New(&jaywt.Options{
+		JWKSURL:       server.URL,
+		SigningMethod: jwt.SigningMethodRS256,
+	})
+
+	raws := make([]string, 20)
+	for i := range raws {
+		raws[i] = strings.TrimPrefix(jwksRequest(t).Header.Get("Authorization"), "Bearer ")
+	}
+
+	_, errs := p.ParseRawConcurrent(context.Background(), raws, 0)
+	for i, err := range errs {
+		if err != nil {
+			t.Errorf("%d: %v", i, err)
+		}
+	}
+
+	if *hits != 1 {
+		t.Errorf("JWKS fetches: Got %d, want 1", *hits)
+	}
+}
+
+func TestParseRawConcurrentCancelled(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	raws := []string{sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject}), sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})}
+	tokens, errs := p.ParseRawConcurrent(ctx, raws, 2)
+	for i := range raws {
+		if tokens[i] != nil || !errors.Is(errs[i], jaywt.ErrContextCancelled) {
+			t.Errorf("%d: Got %v, want %v", i, errs[i], jaywt.ErrContextCancelled)
+		}
+	}
+
+	if tokens, errs = p.ParseRawConcurrent(ctx, nil, 2); len(tokens) != 0 || len(errs) != 0 {
+		t.Errorf("Got %v and %v, want nothing", tokens, errs)
+	}
+}
@@ -0,0 +1,53 @@
+package jaywt
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// JWKSCache stores the JWKS documents fetched from Options.JWKSURL, e.g. in
+// a store shared by all instances of a service, to fetch them less often.
+// Implementations must be safe for concurrent use.
+type JWKSCache interface {
+	// Get returns the cached JWKS document of the URL, or nil if there is
+	// none or it expired.
+	Get(ctx context.Context, url string) ([]byte, error)
+	// Set caches the JWKS document of the URL for the given duration.
+	Set(ctx context.Context, url string, jwks []byte, ttl time.Duration) error
+}
+
+// memoryJWKSCache is the default JWKSCache, keeping documents in memory.
+type memoryJWKSCache struct {
+	mu      sync.Mutex
+	entries map[string]memoryJWKSEntry
+}
+
+type memoryJWKSEntry struct {
+	jwks    []byte
+	expires time.Time
+}
+
+func (c *memoryJWKSCache) Get(_ context.Context, url string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[url]
+	if !ok || time.Now().After(entry.expires) {
+		return nil, nil
+	}
+
+	return entry.jwks, nil
+}
+
+func (c *memoryJWKSCache) Set(_ context.Context, url string, jwks []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.entries == nil {
+		c.entries = make(map[string]memoryJWKSEntry)
+	}
+
+	c.entries[url] = memoryJWKSEntry{jwks: jwks, expires: time.Now().Add(ttl)}
+	return nil
+}
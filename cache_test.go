@@ -0,0 +1,105 @@
+package jaywt_test
+
+import (
+	"context"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestJWKSCacheRead(t *testing.T) {
+	server, hits := sampleJWKSServer(t, http.StatusInternalServerError)
+	defer server.Close()
+
+	cache := &mapJWKSCache{entries: map[string][]byte{
+		server.URL: sampleJWKS(t, rsaJWK(sampleKID, &sampleRSAKey.PublicKey)),
+	}}
+	p := jaywt.New(&jaywt.Options{
+		JWKSURL:       server.URL,
+		JWKSCache:     cache,
+		SigningMethod: jwt.SigningMethodRS256,
+	})
+
+	if _, err := p.Get(jwksRequest(t)); err != nil {
+		t.Error(err)
+	}
+
+	if *hits != 0 {
+		t.Errorf("JWKS fetches: Got %d, want 0", *hits)
+	}
+}
+
+func TestJWKSCacheWrite(t *testing.T) {
+	server, _ := sampleJWKSServer(t, http.StatusOK)
+	defer server.Close()
+
+	cache := &mapJWKSCache{entries: map[string][]byte{}}
+	p := jaywt.New(&jaywt.Options{
+		JWKSURL:       server.URL,
+		JWKSCache:     cache,
+		SigningMethod: jwt.SigningMethodRS256,
+	})
+
+	if err := p.Warm(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if cache.entries[server.URL] == nil {
+		t.Error("JWKS should be cached")
+	}
+
+	if cache.ttl != time.Hour {
+		t.Errorf("TTL: Got %v, want %v", cache.ttl, time.Hour)
+	}
+}
+
+func TestJWKSCacheDefault(t *testing.T) {
+	server, hits := sampleJWKSServer(t, http.StatusOK)
+	defer server.Close()
+
+	p := jaywt.New(&jaywt.Options{
+		JWKSURL:       server.URL,
+		SigningMethod: jwt.SigningMethodRS256,
+	})
+
+	if p.Options.JWKSCache == nil {
+		t.Fatal("JWKSCache should default to an in-memory cache")
+	}
+
+	if err := p.Warm(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if *hits != 1 {
+		t.Errorf("JWKS fetches: Got %d, want 1", *hits)
+	}
+}
+
+// Helper functions
+// ---
+
+// mapJWKSCache is a JWKSCache never expiring documents.
+type mapJWKSCache struct {
+	mu      sync.Mutex
+	entries map[string][]byte
+	ttl     time.Duration
+}
+
+func (c *mapJWKSCache) Get(_ context.Context, url string) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.entries[url], nil
+}
+
+func (c *mapJWKSCache) Set(_ context.Context, url string, jwks []byte, ttl time.Duration) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries[url] = jwks
+	c.ttl = ttl
+	return nil
+}
@@ -0,0 +1,79 @@
+package jaywt
+
+import (
+	"encoding/json"
+	"fmt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"strconv"
+)
+
+// maxExactFloat is the magnitude below which float64 values are integers
+// that can't be the rounding of another one.
+const maxExactFloat = 1 << 53
+
+// ClaimsAsStrings extracts and validates the JWT token from the request,
+// then returns its top-level claims as strings, e.g. for templates or
+// logs. Strings are kept, numbers and booleans are formatted, and other
+// values, like arrays and objects, are encoded as JSON. The conversion is
+// lossy, so it is meant for presentation, not for authorization decisions.
+func (m *Core) ClaimsAsStrings(r *http.Request) (map[string]string, error) {
+	token, err := m.Get(r)
+	if err != nil {
+		return nil, err
+	}
+
+	claims, err := claimsMap(token)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make(map[string]string, len(claims))
+	for name, value := range claims {
+		switch v := value.(type) {
+		case string:
+			res[name] = v
+		case float64:
+			res[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		case json.Number:
+			res[name] = v.String()
+		case bool:
+			res[name] = strconv.FormatBool(v)
+		default:
+			data, err := json.Marshal(v)
+			if err != nil {
+				return nil, fmt.Errorf("Error reading claim '%s': %w", name, err)
+			}
+
+			res[name] = string(data)
+		}
+	}
+
+	return res, nil
+}
+
+// Int64Claim returns the token's named claim as an int64. Numbers decoded
+// as json.Number, with Options.UseJSONNumber, are read exactly. Numbers
+// decoded as float64 are only returned below 2^53, as larger ones may have
+// lost precision. It reports false if the claim is missing, isn't an
+// integer, or doesn't fit.
+func Int64Claim(token *jwt.Token, key string) (int64, bool) {
+	claims, err := claimsMap(token)
+	if err != nil {
+		return 0, false
+	}
+
+	switch v := claims[key].(type) {
+	case json.Number:
+		n, err := v.Int64()
+		return n, err == nil
+	case float64:
+		if v != float64(int64(v)) || v >= maxExactFloat || v <= -maxExactFloat {
+			return 0, false
+		}
+
+		return int64(v), true
+	}
+
+	return 0, false
+}
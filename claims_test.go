@@ -0,0 +1,134 @@
+package jaywt_test
+
+import (
+	"errors"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// 2^53 + 1, the smallest positive integer float64 can't represent
+const sampleUID int64 = 9007199254740993
+
+func TestInt64ClaimUseJSONNumber(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:       sampleKeyfunc,
+		UseJSONNumber: true,
+	})
+
+	token, err := p.ValidateRaw(sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject, "uid": sampleUID}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if uid, ok := jaywt.Int64Claim(token, "uid"); !ok || uid != sampleUID {
+		t.Errorf("Got %d, %t, want %d, true", uid, ok, sampleUID)
+	}
+}
+
+func TestInt64ClaimFloat(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	token, err := p.ValidateRaw(sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   sampleSubject,
+		"uid":   sampleUID,
+		"small": 42,
+		"ratio": 0.5,
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// float64 may have lost the precision, so the helper refuses
+	if uid, ok := jaywt.Int64Claim(token, "uid"); ok {
+		t.Errorf("Got %d, want no value", uid)
+	}
+
+	if small, ok := jaywt.Int64Claim(token, "small"); !ok || small != 42 {
+		t.Errorf("Got %d, %t, want 42, true", small, ok)
+	}
+
+	for _, key := range []string{"ratio", "sub", "missing"} {
+		if v, ok := jaywt.Int64Claim(token, key); ok {
+			t.Errorf("%s: Got %d, want no value", key, v)
+		}
+	}
+}
+
+func TestUseJSONNumberExpired(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:       sampleKeyfunc,
+		UseJSONNumber: true,
+	})
+
+	raw := sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject, "exp": time.Now().Add(-time.Hour).Unix()})
+	if _, err := p.ValidateRaw(raw); !errors.Is(err, jaywt.ErrTokenExpired) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrTokenExpired)
+	}
+}
+
+func TestClaimsAsStrings(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":   sampleSubject,
+		"iat":   1700000000,
+		"ratio": 0.25,
+		"admin": true,
+		"roles": []string{"read", "write"},
+		"org":   map[string]interface{}{"id": "acme"},
+		"extra": nil,
+	})
+
+	got, err := p.ClaimsAsStrings(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]string{
+		"sub":   sampleSubject,
+		"iat":   "1700000000",
+		"ratio": "0.25",
+		"admin": "true",
+		"roles": `["read","write"]`,
+		"org":   `{"id":"acme"}`,
+		"extra": "null",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %v, want %v", got, want)
+	}
+}
+
+func TestClaimsAsStringsUseJSONNumber(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:       sampleKeyfunc,
+		UseJSONNumber: true,
+	})
+
+	got, err := p.ClaimsAsStrings(sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"uid": sampleUID}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got["uid"] != "9007199254740993" {
+		t.Errorf("Got %s, want 9007199254740993", got["uid"])
+	}
+}
+
+func TestClaimsAsStringsUnauthorized(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	if _, err := p.ClaimsAsStrings(httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
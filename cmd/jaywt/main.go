@@ -0,0 +1,70 @@
+// Command jaywt validates a JWT token read from stdin and prints its claims as
+// JSON, or the validation error. The exit status is 1 if validation fails.
+//
+//	echo "$TOKEN" | jaywt -secret-env JWT_SECRET -iss https://example.com
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"os"
+	"strings"
+	"time"
+)
+
+func main() {
+	alg := flag.String("alg", "HS256", "expected signing algorithm")
+	secretEnv := flag.String("secret-env", "", "environment variable holding the HMAC secret")
+	jwksURL := flag.String("jwks", "", "URL of the JWKS to select keys from")
+	iss := flag.String("iss", "", "expected 'iss' claim")
+	aud := flag.String("aud", "", "expected 'aud' claim")
+	leeway := flag.Duration("leeway", 0, "allowed clock skew")
+	flag.Parse()
+
+	if err := run(*alg, *secretEnv, *jwksURL, *iss, *aud, *leeway); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(alg, secretEnv, jwksURL, iss, aud string, leeway time.Duration) error {
+	method := jwt.GetSigningMethod(alg)
+	if method == nil {
+		return fmt.Errorf("Unknown signing algorithm '%s'", alg)
+	}
+
+	o := &jaywt.Options{
+		SigningMethod: method,
+		JWKSURL:       jwksURL,
+		Issuer:        iss,
+		Audience:      aud,
+		Leeway:        leeway,
+	}
+
+	if secretEnv != "" {
+		keyfunc, err := jaywt.NewHMACKeyfuncFromEnv(secretEnv, false)
+		if err != nil {
+			return err
+		}
+
+		o.Keyfunc = keyfunc
+	}
+
+	raw, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && raw == "" {
+		return fmt.Errorf("Error reading token: %w", err)
+	}
+
+	token, err := jaywt.ValidateString(strings.TrimSpace(raw), o)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(token.Claims)
+}
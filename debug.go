@@ -0,0 +1,74 @@
+package jaywt
+
+import (
+	"fmt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+)
+
+// DebugResult is the outcome of a check made by GetDebug.
+type DebugResult struct {
+	// The token's decoded header. It's not verified.
+	Header map[string]interface{}
+	// The token's decoded claims. They're not verified.
+	Claims map[string]interface{}
+	// The error the token check failed with, or nil if the token is valid.
+	Error error
+}
+
+// GetDebug extracts and validates the JWT token from the request like Get,
+// but also decodes its header and claims, even if it's invalid, to diagnose
+// why it was rejected. The token isn't consumed in ReplayStore, its session
+// isn't touched, and the check isn't reported to OnValidate, AuditFunc or
+// Stats. It only fails if the token can't be extracted or decoded;
+// validation errors are in the result.
+//
+// WARNING: Never expose it publicly. The unverified claims can be made up by
+// anyone, and the errors reveal how tokens are validated. Use it only on
+// protected diagnostics endpoints.
+func (m *Core) GetDebug(r *http.Request) (*DebugResult, error) {
+	raw, err := m.rawToken(r)
+	if err != nil {
+		return nil, err
+	}
+
+	header, err := m.decodeHeader(raw)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding header: %w", err)
+	}
+
+	claims, err := m.decodeClaims(raw)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DebugResult{
+		Header: header,
+		Claims: claims,
+		Error:  m.debugCheck(r, raw),
+	}, nil
+}
+
+// Helper functions
+// ---
+
+// debugCheck validates the raw token like Get, but without consuming it in
+// ReplayStore, touching its session, or reporting the check.
+func (m *Core) debugCheck(r *http.Request, raw string) error {
+	if m.Options.RequireTLS && !m.secure(r) {
+		return ErrInsecureTransport
+	}
+
+	raw, err := m.withPayload(r, raw)
+	if err != nil {
+		return err
+	}
+
+	res, err := m.validate(r.Context(), r, raw, jwt.MapClaims{})
+	if err != nil {
+		return err
+	}
+
+	_, err = verifiedToken(res)
+	return err
+}
@@ -0,0 +1,104 @@
+package jaywt_test
+
+import (
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestGetDebugOk(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+
+	res, err := p.GetDebug(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Error != nil {
+		t.Error(res.Error)
+	}
+
+	if res.Header["alg"] != "HS256" || res.Claims["sub"] != sampleSubject {
+		t.Errorf("Got %v and %v, want the decoded header and claims", res.Header, res.Claims)
+	}
+}
+
+func TestGetDebugInvalid(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: badKeyfunc,
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+		"exp": time.Now().Add(-1 * time.Hour).Unix(),
+	})
+
+	res, err := p.GetDebug(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Error == nil {
+		t.Error("Result should have the validation error")
+	}
+
+	if res.Claims["sub"] != sampleSubject {
+		t.Errorf("Got %v, want the decoded claims", res.Claims)
+	}
+}
+
+var debugTableBad = []string{
+	"",
+	"Bearer asdf",
+	"Bearer " + headerTokenOk,
+}
+
+func TestGetDebugBad(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	for _, header := range debugTableBad {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", header)
+		if _, err := p.GetDebug(req); err == nil {
+			t.Errorf("%q: Error was expected, got nil", header)
+		}
+	}
+}
+
+func TestGetDebugNoSideEffects(t *testing.T) {
+	var validated int
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:     sampleKeyfunc,
+		ReplayStore: jaywt.NewMemoryReplayStore(),
+		TrackStats:  true,
+		OnValidate: func(_ *http.Request, _ *jaywt.Result, _ error) {
+			validated++
+		},
+	})
+
+	claims := jwt.MapClaims{"jti": "link-1", "exp": time.Now().Add(time.Hour).Unix()}
+	res, err := p.GetDebug(sampleRequest(t, jwt.SigningMethodHS256, claims))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Error != nil {
+		t.Error(res.Error)
+	}
+
+	if validated != 0 || p.Stats().Successes != 0 {
+		t.Error("GetDebug should not report the check")
+	}
+
+	// The token is still usable
+	if _, err = p.Get(sampleRequest(t, jwt.SigningMethodHS256, claims)); err != nil {
+		t.Error(err)
+	}
+}
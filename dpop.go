@@ -0,0 +1,145 @@
+package jaywt
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// dpopMaxAge is how far the DPoP proof's 'iat' can be from now.
+const dpopMaxAge = 5 * time.Minute
+
+// dpopMethods are the algorithms DPoP proofs can be signed with.
+var dpopMethods = []string{
+	"RS256", "RS384", "RS512",
+	"PS256", "PS384", "PS512",
+	"ES256", "ES384", "ES512",
+}
+
+// GetWithDPoP extracts and validates the JWT token from the request, then
+// verifies the request's DPoP proof (RFC 9449). The proof must be signed by
+// the key in its 'jwk' header, match the request's method and URL and the
+// access token, and the key's thumbprint must match the token's 'cnf.jkt'
+// claim. The URL's scheme is https if the request was made over TLS, as
+// RequireTLS decides, honoring Options.TrustForwardedProto. The proof's 'jti'
+// is remembered in Options.DPoPReplayStore, and replayed proofs fail with
+// ErrDPoPReplay. It returns the parsed token, if successful.
+//
+// DPoP tokens are sent with the 'DPoP' scheme instead of 'Bearer', so
+// Options.Extractor has to accept it.
+func (m *Core) GetWithDPoP(r *http.Request) (*jwt.Token, error) {
+	return m.getVerified(r, jwt.MapClaims{}, ValidatorFunc(m.checkDPoP))
+}
+
+// Helper functions
+// ---
+
+// checkDPoP checks the request's DPoP proof against the access token.
+func (m *Core) checkDPoP(token *jwt.Token, r *http.Request) error {
+	proofs := r.Header[http.CanonicalHeaderKey("DPoP")]
+	if len(proofs) == 0 || proofs[0] == "" {
+		return ErrDPoPMissing
+	}
+
+	if len(proofs) > 1 {
+		return ErrDPoPInvalid
+	}
+
+	// Verify proof signature
+	var key *jwk
+	parser := &jwt.Parser{ValidMethods: dpopMethods}
+	proof, err := parser.Parse(proofs[0], func(proof *jwt.Token) (interface{}, error) {
+		if proof.Header["typ"] != "dpop+jwt" {
+			return nil, ErrDPoPInvalid
+		}
+
+		k, pub, err := publicJWK(proof.Header["jwk"])
+		key = k
+		return pub, err
+	})
+	if err != nil {
+		return ErrDPoPInvalid
+	}
+
+	// Verify proof claims
+	claims := proof.Claims.(jwt.MapClaims)
+	if !dpopMatches(claims, r, m.secure(r), token.Raw) {
+		return ErrDPoPMismatch
+	}
+
+	// Verify binding
+	thumbprint, err := key.thumbprint()
+	if err != nil {
+		return ErrDPoPInvalid
+	}
+
+	cnf, _ := token.Claims.(jwt.MapClaims)["cnf"].(map[string]interface{})
+	jkt, _ := cnf["jkt"].(string)
+	if subtle.ConstantTimeCompare([]byte(jkt), []byte(thumbprint)) != 1 {
+		return ErrDPoPBinding
+	}
+
+	// Detect replays last, as it marks the proof used
+	return m.checkProofReplay(claims)
+}
+
+// checkProofReplay marks the DPoP proof's 'jti' as used until the proof is
+// too old to be accepted, failing if it already was.
+func (m *Core) checkProofReplay(claims jwt.MapClaims) error {
+	jti, _ := claims["jti"].(string)
+	iat, _ := numericClaim(claims, "iat")
+	seen, err := m.Options.DPoPReplayStore.CheckAndMark(jti, time.Unix(iat, 0).Add(dpopMaxAge))
+	if err != nil {
+		return fmt.Errorf("Error checking DPoP replay: %w", err)
+	}
+
+	if seen {
+		return ErrDPoPReplay
+	}
+
+	return nil
+}
+
+func dpopMatches(claims jwt.MapClaims, r *http.Request, secure bool, accessToken string) bool {
+	if jti, _ := claims["jti"].(string); jti == "" {
+		return false
+	}
+
+	if htm, _ := claims["htm"].(string); htm != r.Method {
+		return false
+	}
+
+	if htu, _ := claims["htu"].(string); !sameURL(htu, r, secure) {
+		return false
+	}
+
+	iat, ok := numericClaim(claims, "iat")
+	if d := time.Since(time.Unix(iat, 0)); !ok || d > dpopMaxAge || d < -dpopMaxAge {
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(accessToken))
+	ath, _ := claims["ath"].(string)
+	return subtle.ConstantTimeCompare([]byte(ath), []byte(jwt.EncodeSegment(digest[:]))) == 1
+}
+
+// sameURL reports whether the 'htu' claim is the request's URL, ignoring
+// the query and fragment. Secure requests have the https scheme.
+func sameURL(htu string, r *http.Request, secure bool) bool {
+	u, err := url.Parse(htu)
+	if err != nil {
+		return false
+	}
+
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+
+	return strings.EqualFold(u.Scheme, scheme) && strings.EqualFold(u.Host, r.Host) && u.Path == r.URL.Path
+}
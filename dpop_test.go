@@ -0,0 +1,192 @@
+package jaywt_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const dpopURL = "http://example.com/resource"
+
+func TestGetWithDPoPOk(t *testing.T) {
+	key := sampleECKey(t)
+	req := dpopRequest(t, key, dpopThumbprint(t, &key.PublicKey), nil)
+
+	_, err := newDPoPCore().GetWithDPoP(req)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetWithDPoPMissing(t *testing.T) {
+	key := sampleECKey(t)
+	req := dpopRequest(t, key, dpopThumbprint(t, &key.PublicKey), nil)
+	req.Header.Del("DPoP")
+
+	_, err := newDPoPCore().GetWithDPoP(req)
+	if err != jaywt.ErrDPoPMissing {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrDPoPMissing)
+	}
+}
+
+func TestGetWithDPoPInvalid(t *testing.T) {
+	key := sampleECKey(t)
+	req := dpopRequest(t, key, dpopThumbprint(t, &key.PublicKey), nil)
+	req.Header.Set("DPoP", req.Header.Get("DPoP")+"tampered")
+
+	_, err := newDPoPCore().GetWithDPoP(req)
+	if err != jaywt.ErrDPoPInvalid {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrDPoPInvalid)
+	}
+}
+
+func TestGetWithDPoPMismatch(t *testing.T) {
+	key := sampleECKey(t)
+	table := []jwt.MapClaims{
+		{"htm": http.MethodPost},
+		{"htu": "http://example.com/other"},
+		{"iat": time.Now().Add(-1 * time.Hour).Unix()},
+		{"ath": "wrong"},
+		{"jti": ""},
+	}
+
+	for _, claims := range table {
+		req := dpopRequest(t, key, dpopThumbprint(t, &key.PublicKey), claims)
+
+		_, err := newDPoPCore().GetWithDPoP(req)
+		if err != jaywt.ErrDPoPMismatch {
+			t.Errorf("%v: Got %v, want %v", claims, err, jaywt.ErrDPoPMismatch)
+		}
+	}
+}
+
+func TestGetWithDPoPBinding(t *testing.T) {
+	key := sampleECKey(t)
+	other := sampleECKey(t)
+	req := dpopRequest(t, key, dpopThumbprint(t, &other.PublicKey), nil)
+
+	_, err := newDPoPCore().GetWithDPoP(req)
+	if err != jaywt.ErrDPoPBinding {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrDPoPBinding)
+	}
+}
+
+func TestGetWithDPoPForwardedProto(t *testing.T) {
+	key := sampleECKey(t)
+	p := newDPoPCore()
+
+	// Behind a proxy terminating TLS, the request itself is plain HTTP
+	req := dpopRequest(t, key, dpopThumbprint(t, &key.PublicKey), jwt.MapClaims{"htu": "https://example.com/resource"})
+	req.Header.Set("X-Forwarded-Proto", "https")
+	if _, err := p.GetWithDPoP(req); err != jaywt.ErrDPoPMismatch {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrDPoPMismatch)
+	}
+
+	p.Options.TrustForwardedProto = true
+	if _, err := p.GetWithDPoP(req); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetWithDPoPReplay(t *testing.T) {
+	key := sampleECKey(t)
+	p := newDPoPCore()
+
+	if _, err := p.GetWithDPoP(dpopRequest(t, key, dpopThumbprint(t, &key.PublicKey), nil)); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.GetWithDPoP(dpopRequest(t, key, dpopThumbprint(t, &key.PublicKey), nil)); err != jaywt.ErrDPoPReplay {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrDPoPReplay)
+	}
+
+	// Only the proof is single-use, not the access token
+	req := dpopRequest(t, key, dpopThumbprint(t, &key.PublicKey), jwt.MapClaims{"jti": "proof-2"})
+	if _, err := p.GetWithDPoP(req); err != nil {
+		t.Error(err)
+	}
+}
+
+// Helper functions
+// ---
+
+func newDPoPCore() *jaywt.Core {
+	return jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Extractor: func(r *http.Request) (string, error) {
+			return strings.TrimPrefix(r.Header.Get("Authorization"), "DPoP "), nil
+		},
+	})
+}
+
+func sampleECKey(t *testing.T) *ecdsa.PrivateKey {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return key
+}
+
+func ecJWK(key *ecdsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "EC",
+		"crv": "P-256",
+		"x":   jwt.EncodeSegment(key.X.FillBytes(make([]byte, 32))),
+		"y":   jwt.EncodeSegment(key.Y.FillBytes(make([]byte, 32))),
+	}
+}
+
+func dpopThumbprint(t *testing.T, key *ecdsa.PublicKey) string {
+	k := ecJWK(key)
+	data, err := json.Marshal(map[string]string{"crv": k["crv"], "kty": k["kty"], "x": k["x"], "y": k["y"]})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha256.Sum256(data)
+	return jwt.EncodeSegment(digest[:])
+}
+
+// dpopRequest builds a request with an access token bound to jkt and a DPoP
+// proof signed by key. The overrides replace the proof's claims.
+func dpopRequest(t *testing.T, key *ecdsa.PrivateKey, jkt string, overrides jwt.MapClaims) *http.Request {
+	access := sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+		"cnf": map[string]string{"jkt": jkt},
+	})
+
+	ath := sha256.Sum256([]byte(access))
+	claims := jwt.MapClaims{
+		"jti": "proof-1",
+		"htm": http.MethodGet,
+		"htu": dpopURL,
+		"iat": time.Now().Unix(),
+		"ath": jwt.EncodeSegment(ath[:]),
+	}
+	for k, v := range overrides {
+		claims[k] = v
+	}
+
+	proof := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	proof.Header["typ"] = "dpop+jwt"
+	proof.Header["jwk"] = ecJWK(&key.PublicKey)
+	signed, err := proof.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, dpopURL, nil)
+	req.Header.Set("Authorization", "DPoP "+access)
+	req.Header.Set("DPoP", signed)
+	return req
+}
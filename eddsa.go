@@ -0,0 +1,55 @@
+package jaywt
+
+import (
+	"crypto/ed25519"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+)
+
+// SigningMethodEdDSA implements the EdDSA algorithm with Ed25519 keys, which
+// jwt-go v3 doesn't ship. It is registered with jwt-go when the package is
+// imported, so tokens with alg 'EdDSA' parse like any other.
+//
+// Sign expects an ed25519.PrivateKey, Verify an ed25519.PublicKey.
+var SigningMethodEdDSA jwt.SigningMethod = &signingMethodEd25519{}
+
+func init() {
+	jwt.RegisterSigningMethod(SigningMethodEdDSA.Alg(), func() jwt.SigningMethod {
+		return SigningMethodEdDSA
+	})
+}
+
+// Helper functions
+// ---
+
+type signingMethodEd25519 struct{}
+
+func (m *signingMethodEd25519) Alg() string {
+	return "EdDSA"
+}
+
+func (m *signingMethodEd25519) Verify(signingString, signature string, key interface{}) error {
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok || len(pub) != ed25519.PublicKeySize {
+		return jwt.ErrInvalidKeyType
+	}
+
+	sig, err := jwt.DecodeSegment(signature)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(pub, []byte(signingString), sig) {
+		return ErrEd25519Verification
+	}
+
+	return nil
+}
+
+func (m *signingMethodEd25519) Sign(signingString string, key interface{}) (string, error) {
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok || len(priv) != ed25519.PrivateKeySize {
+		return "", jwt.ErrInvalidKeyType
+	}
+
+	return jwt.EncodeSegment(ed25519.Sign(priv, []byte(signingString))), nil
+}
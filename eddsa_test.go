@@ -0,0 +1,45 @@
+package jaywt_test
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"testing"
+)
+
+func TestSigningMethodEdDSARegistered(t *testing.T) {
+	if m := jwt.GetSigningMethod("EdDSA"); m != jaywt.SigningMethodEdDSA {
+		t.Errorf("Got %v, want %v", m, jaywt.SigningMethodEdDSA)
+	}
+}
+
+func TestSigningMethodEdDSA(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	sig, err := jaywt.SigningMethodEdDSA.Sign("payload", priv)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := jaywt.SigningMethodEdDSA.Verify("payload", sig, pub); err != nil {
+		t.Error(err)
+	}
+
+	if err := jaywt.SigningMethodEdDSA.Verify("tampered", sig, pub); err != jaywt.ErrEd25519Verification {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrEd25519Verification)
+	}
+}
+
+func TestSigningMethodEdDSAKeyType(t *testing.T) {
+	if _, err := jaywt.SigningMethodEdDSA.Sign("payload", []byte(sampleSecret)); err != jwt.ErrInvalidKeyType {
+		t.Errorf("Got %v, want %v", err, jwt.ErrInvalidKeyType)
+	}
+
+	if err := jaywt.SigningMethodEdDSA.Verify("payload", "c2ln", &sampleRSAKey.PublicKey); err != jwt.ErrInvalidKeyType {
+		t.Errorf("Got %v, want %v", err, jwt.ErrInvalidKeyType)
+	}
+}
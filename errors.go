@@ -0,0 +1,197 @@
+package jaywt
+
+import "errors"
+
+// Errors returned by the checking functions. They can be compared against
+// directly to tell the failure reasons apart, or with errors.Is for the ones
+// that are wrapped.
+var (
+	// ErrTokenExpired is returned when the token's 'exp' claim is in the past.
+	// It is wrapped, e.g. when the claim is missing and required by
+	// Options.TreatNoExpAsExpired, so check it with errors.Is.
+	ErrTokenExpired = errors.New("Token is expired")
+	// ErrKeyUnavailable should be returned by a Keyfunc that can't obtain the
+	// key right now, e.g. because the key server is down, possibly wrapped.
+	// It is the only Keyfunc error that activates DegradedMode.
+	ErrKeyUnavailable = errors.New("Key is unavailable")
+	// ErrAtHashMismatch is returned when the token's 'at_hash' claim doesn't
+	// match the access token.
+	ErrAtHashMismatch = errors.New("Token 'at_hash' does not match the access token")
+	// ErrNonceMismatch is returned when the token's 'nonce' claim doesn't
+	// match the expected one.
+	ErrNonceMismatch = errors.New("Token 'nonce' does not match")
+	// ErrClaimsTooLarge is returned when the token's claims exceed
+	// Options.MaxClaimsBytes.
+	ErrClaimsTooLarge = errors.New("Token claims are too large")
+	// ErrInvalidAudience is returned when the token's 'aud' claim doesn't
+	// contain Options.Audience.
+	ErrInvalidAudience = errors.New("Token audience is invalid")
+	// ErrInvalidIssuer is returned when the token's 'iss' claim isn't
+	// Options.Issuer.
+	ErrInvalidIssuer = errors.New("Token issuer is invalid")
+	// ErrNoCredentials is returned when none of the extractors chained by
+	// FromFirst found a token.
+	ErrNoCredentials = errors.New("No credentials found")
+	// ErrDPoPMissing is returned when the request has no 'DPoP' proof header.
+	ErrDPoPMissing = errors.New("DPoP proof not found")
+	// ErrDPoPInvalid is returned when the DPoP proof is malformed or its
+	// signature is invalid.
+	ErrDPoPInvalid = errors.New("DPoP proof is invalid")
+	// ErrDPoPMismatch is returned when the DPoP proof's claims don't match
+	// the request or the access token.
+	ErrDPoPMismatch = errors.New("DPoP proof does not match the request")
+	// ErrDPoPBinding is returned when the access token's 'cnf.jkt' claim
+	// doesn't match the DPoP proof's key.
+	ErrDPoPBinding = errors.New("Token is not bound to the DPoP proof key")
+	// ErrDPoPReplay is returned when Options.DPoPReplayStore has seen the
+	// DPoP proof's 'jti' claim before.
+	ErrDPoPReplay = errors.New("DPoP proof was already used")
+	// ErrInsufficientACR is returned when the token's 'acr' or 'amr' claims
+	// don't meet the required authentication context.
+	ErrInsufficientACR = errors.New("Token authentication context is insufficient")
+	// ErrClaimsRootMissing is returned when the token has no claims nested
+	// under Options.ClaimsRoot.
+	ErrClaimsRootMissing = errors.New("Token claims root is missing")
+	// ErrRevokedKID is returned when the token's 'kid' header is one of the
+	// revoked key IDs.
+	ErrRevokedKID = errors.New("Token key ID is revoked")
+	// ErrInsecureTransport is returned when RequireTLS is on and the request
+	// wasn't made over TLS.
+	ErrInsecureTransport = errors.New("Token must be sent over TLS")
+	// ErrNoClientCert is returned when the request has no client TLS
+	// certificate to bind the token to.
+	ErrNoClientCert = errors.New("Client certificate not found")
+	// ErrCertBindingMismatch is returned when the token's 'cnf.x5t#S256'
+	// claim doesn't match the client TLS certificate.
+	ErrCertBindingMismatch = errors.New("Token is not bound to the client certificate")
+	// ErrClaimInvalid is wrapped by the errors of Options.ClaimValidators,
+	// naming the invalid claim. Check for it with errors.Is.
+	ErrClaimInvalid = errors.New("Token claim is invalid")
+	// ErrTokenTooOld is returned when the token was issued longer than MaxAge
+	// ago, or has no 'iat' claim.
+	ErrTokenTooOld = errors.New("Token is too old")
+	// ErrUnsupportedB64False is returned when the token declares an unencoded
+	// payload with the "b64": false header (RFC 7797), which isn't supported.
+	ErrUnsupportedB64False = errors.New("Token with unencoded payload is not supported")
+	// ErrUnsupportedCritical is returned when the token's 'crit' header lists
+	// an extension that isn't in KnownCritical, or is invalid.
+	ErrUnsupportedCritical = errors.New("Token critical header extension is not supported")
+	// ErrTokenReplay is returned when ReplayStore has seen the token's 'jti'
+	// claim before, or the token has none.
+	ErrTokenReplay = errors.New("Token was already used")
+	// ErrDecryptionFailed is wrapped by the errors of decrypting tokens with
+	// NestedDecrypt. Check for it with errors.Is.
+	ErrDecryptionFailed = errors.New("Error decrypting token")
+	// ErrUnknownKID is wrapped by the errors of tokens whose 'kid' header
+	// names none of the keys, e.g. of the JWKS fetched from JWKSURL even
+	// after refetching it, which name it. Check for it with errors.Is.
+	ErrUnknownKID = errors.New("Unknown key ID")
+	// ErrEd25519Verification is returned when an EdDSA signature doesn't
+	// match the Ed25519 public key.
+	ErrEd25519Verification = errors.New("Ed25519 verification error")
+	// ErrContextCancelled wraps the request context's error when it's done
+	// before a step that may touch the network, e.g. selecting the key.
+	// Check for it with errors.Is.
+	ErrContextCancelled = errors.New("Request context is done")
+	// ErrBadPrefix is returned when the extracted token lacks
+	// Options.TokenPrefix.
+	ErrBadPrefix = errors.New("Token prefix is missing")
+	// ErrAssociatedDataMismatch is wrapped by the errors of tokens whose
+	// signature isn't bound to the data from Options.AssociatedData. Check
+	// for it with errors.Is.
+	ErrAssociatedDataMismatch = errors.New("Token is not bound to the associated data")
+	// ErrSubjectMismatch is returned when the token's 'sub' claim isn't the
+	// expected one.
+	ErrSubjectMismatch = errors.New("Token 'sub' does not match")
+	// ErrStaleToken is wrapped by the errors of FreshTokenRequired, which
+	// say how old the token is. Check for it with errors.Is.
+	ErrStaleToken = errors.New("Token is not fresh")
+	// ErrDisallowedAMR is wrapped by the errors of tokens whose 'amr' claim
+	// lists a method in Options.DisallowedAMR. Check for it with errors.Is.
+	ErrDisallowedAMR = errors.New("Token authentication method is disallowed")
+	// ErrInsufficientScope is wrapped by the errors of tokens lacking the
+	// scopes required by GetWithScopes or GetWithAnyScope, which name them.
+	// Check for it with errors.Is.
+	ErrInsufficientScope = errors.New("Token scope is insufficient")
+	// ErrUntrustedIssuer is returned when the token's 'iss' claim isn't
+	// trusted to derive the JWKS URL from, see Options.JWKSURLFromIssuer.
+	ErrUntrustedIssuer = errors.New("Token issuer is not trusted")
+	// ErrWeakKey is wrapped by the errors of keys smaller than
+	// Options.MinRSAKeySize or Options.MinECKeySize, which say their size.
+	// Check for it with errors.Is.
+	ErrWeakKey = errors.New("Key is too weak")
+	// ErrNotDetached is returned by VerifyWebhook when the token carries its
+	// own payload, instead of signing the request's body.
+	ErrNotDetached = errors.New("Token payload is not detached")
+	// ErrEmptyBearerToken is returned by FromAuthHeader for a 'Bearer' scheme
+	// without a token, and by the checks if Options.StrictEmptyToken is on.
+	ErrEmptyBearerToken = errors.New("Authorization header has an empty token")
+	// ErrAmbiguousKey is returned when the token's 'kid' header names a key
+	// of another issuer than its 'iss' claim, with NewFederation or
+	// NewKeyfuncByIssuer.
+	ErrAmbiguousKey = errors.New("Key ID belongs to another issuer")
+	// ErrEmailNotVerified is wrapped by the errors of RequireVerifiedEmail
+	// for tokens without a verified email. Check for it with errors.Is.
+	ErrEmailNotVerified = errors.New("Token email is not verified")
+	// ErrForbiddenAudience is wrapped by the errors of tokens with one of
+	// Options.ForbiddenAudiences in their 'aud' claim, which name it. Check
+	// for it with errors.Is.
+	ErrForbiddenAudience = errors.New("Token audience is forbidden")
+	// ErrAlgMismatchForKID is returned when the token's 'alg' header isn't
+	// the algorithm declared by the JWK its 'kid' header selects.
+	ErrAlgMismatchForKID = errors.New("Token algorithm does not match the key")
+	// ErrWrongTokenUse is wrapped by the errors of tokens whose use isn't
+	// Options.ExpectedTokenUse, e.g. refresh tokens sent as access tokens.
+	// Check for it with errors.Is.
+	ErrWrongTokenUse = errors.New("Token use is wrong")
+	// ErrInvalidJTI is wrapped by the errors of RequireUUIDJTI for tokens
+	// whose 'jti' claim is missing or isn't a UUID. Check for it with
+	// errors.Is.
+	ErrInvalidJTI = errors.New("Token ID is not a UUID")
+	// ErrInvalidType is wrapped by the errors of tokens whose 'typ' header
+	// isn't one of Options.AllowedTypes, or is missing with
+	// Options.RequireTyp. Check for it with errors.Is.
+	ErrInvalidType = errors.New("Token type is invalid")
+	// ErrConfirmationKey is returned by GetWithAssertion when the token's
+	// 'cnf.jwk' claim is missing or isn't a public key.
+	ErrConfirmationKey = errors.New("Token has no confirmation key")
+	// ErrAssertionInvalid is returned by GetWithAssertion when the request's
+	// assertion is missing, malformed, or not signed by the token's
+	// confirmation key.
+	ErrAssertionInvalid = errors.New("Assertion is invalid")
+	// ErrAssertionChallenge is returned by GetWithAssertion when the
+	// request's assertion is over another challenge.
+	ErrAssertionChallenge = errors.New("Assertion does not match the challenge")
+	// ErrRefreshExpired is returned by Refresh when the token's session is
+	// older than Options.RefreshMaxLifetime.
+	ErrRefreshExpired = errors.New("Token session can no longer be refreshed")
+)
+
+// errTokenNotFound is returned when the request has no token.
+var errTokenNotFound = errors.New("Token not found")
+
+// Forbidden marks the error as an authorization failure, meaning the token is
+// valid but doesn't grant access, e.g. when returned by a custom Validator.
+// The default ErrorHandler responds to such errors with 403 Forbidden.
+func Forbidden(err error) error {
+	return authorizationError{err}
+}
+
+// IsAuthorizationError reports whether the error is an authorization failure:
+// ErrInvalidAudience, ErrForbiddenAudience, ErrInsufficientACR,
+// ErrInsufficientScope, or an error marked by Forbidden. Other errors are
+// authentication failures.
+func IsAuthorizationError(err error) bool {
+	var authz authorizationError
+	return errors.Is(err, ErrInvalidAudience) || errors.Is(err, ErrForbiddenAudience) ||
+		errors.Is(err, ErrInsufficientACR) || errors.Is(err, ErrInsufficientScope) ||
+		errors.As(err, &authz)
+}
+
+// authorizationError is an error marked by Forbidden.
+type authorizationError struct {
+	err error
+}
+
+func (e authorizationError) Error() string { return e.err.Error() }
+func (e authorizationError) Unwrap() error { return e.err }
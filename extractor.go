@@ -0,0 +1,148 @@
+package jaywt
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// FromFirst returns an extractor trying the supplied extractors in order
+// and returning the first token found. Errors stop the chain. If none of them
+// finds a token, it fails with ErrNoCredentials, which the checking functions
+// pass through, unlike the generic 'Token not found' error.
+func FromFirst(extractors ...TokenExtractor) TokenExtractor {
+	return func(r *http.Request) (string, error) {
+		for _, extractor := range extractors {
+			token, err := extractor(r)
+			if err != nil {
+				return "", err
+			}
+
+			if token != "" {
+				return token, nil
+			}
+		}
+
+		return "", ErrNoCredentials
+	}
+}
+
+// NamedExtractor is a TokenExtractor with the name of its source, e.g.
+// "cookie", used by FromSources.
+type NamedExtractor struct {
+	Name      string
+	Extractor TokenExtractor
+}
+
+// FromSources works like FromFirst, but also reports the name of the source
+// the token was found in as Result.Source, and in the logs, e.g. to apply
+// different trust levels to tokens from cookies and headers. The source is
+// reported when it is the Options.Extractor New is called with, not wrapped
+// by another extractor.
+func FromSources(sources ...NamedExtractor) TokenExtractor {
+	return namedSources(sources).extract
+}
+
+// FromSplit returns an extractor reassembling a token whose parts are
+// delivered separately, e.g. 'header.payload' in a cookie and the signature
+// in a header. The joiner combines both parts; if nil, they are joined with
+// a dot. If neither part is present, it returns an empty string.
+func FromSplit(payloadExtractor, signatureExtractor TokenExtractor, joiner func(payload, sig string) string) TokenExtractor {
+	if joiner == nil {
+		joiner = func(payload, sig string) string {
+			return payload + "." + sig
+		}
+	}
+
+	return func(r *http.Request) (string, error) {
+		payload, err := payloadExtractor(r)
+		if err != nil {
+			return "", err
+		}
+
+		sig, err := signatureExtractor(r)
+		if err != nil {
+			return "", err
+		}
+
+		if payload == "" && sig == "" {
+			return "", nil // No error, just no token
+		}
+
+		if payload == "" || sig == "" {
+			return "", errors.New("Split token is incomplete")
+		}
+
+		return joiner(payload, sig), nil
+	}
+}
+
+// FromTrailer returns an extractor reading the token from the named HTTP
+// trailer, for streaming uploads sending it after the body. Trailers are only
+// populated once the body is fully read, so the extractor must run after the
+// handler consumed it; it won't work with Handler, which checks the token
+// before calling the next handler. If the trailer is missing, it returns an
+// empty string.
+func FromTrailer(name string) TokenExtractor {
+	return func(r *http.Request) (string, error) {
+		return r.Trailer.Get(name), nil
+	}
+}
+
+// FromDelimitedHeader returns an extractor reading one of several tokens
+// concatenated in the named header, e.g. 'user-token;service-token'. The
+// header is split by the delimiter, and the part at the index is returned
+// without surrounding spaces. If the header is missing, it returns an empty
+// string; if it has too few parts, an error.
+func FromDelimitedHeader(name, delim string, index int) TokenExtractor {
+	return func(r *http.Request) (string, error) {
+		header := r.Header.Get(name)
+		if header == "" {
+			return "", nil // No error, just no token
+		}
+
+		parts := strings.Split(header, delim)
+		if index < 0 || index >= len(parts) {
+			return "", fmt.Errorf("Header %s has %d parts, wanted the one at index %d", name, len(parts), index)
+		}
+
+		return strings.TrimSpace(parts[index]), nil
+	}
+}
+
+// Helper functions
+// ---
+
+// sourceKey is the context key of the source name FromSources reports.
+type sourceKey struct{}
+
+// namedSources are the sources of an extractor returned by FromSources.
+type namedSources []NamedExtractor
+
+func (s namedSources) extract(r *http.Request) (string, error) {
+	for _, source := range s {
+		token, err := source.Extractor(r)
+		if err != nil {
+			return "", err
+		}
+
+		if token != "" {
+			if name, ok := r.Context().Value(sourceKey{}).(*string); ok {
+				*name = source.Name
+			}
+
+			return token, nil
+		}
+	}
+
+	return "", ErrNoCredentials
+}
+
+// reportsSource reports whether the extractor was returned by FromSources,
+// so New only makes the checks pay for a context to report the source in
+// when needed. The extractors share the code of namedSources.extract.
+func reportsSource(e TokenExtractor) bool {
+	return e != nil && reflect.ValueOf(e).Pointer() == reflect.ValueOf(namedSources(nil).extract).Pointer()
+}
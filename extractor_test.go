@@ -0,0 +1,379 @@
+package jaywt_test
+
+import (
+	"errors"
+	"fmt"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const splitPayload = "asdf1234.asdfasdf12341234"
+const splitSignature = "adsf1234"
+
+func TestFromSplitOk(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "payload", Value: splitPayload})
+	req.Header.Set("Authorization", "Bearer "+splitSignature)
+
+	extractor := jaywt.FromSplit(cookieExtractor("payload"), jaywt.FromAuthHeader, nil)
+	token, err := extractor(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if token != headerTokenOk {
+		t.Errorf("Token: %s, want %s", token, headerTokenOk)
+	}
+}
+
+func TestFromSplitJoiner(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "payload", Value: splitPayload + "."})
+	req.Header.Set("Authorization", "Bearer "+splitSignature)
+
+	extractor := jaywt.FromSplit(cookieExtractor("payload"), jaywt.FromAuthHeader, func(payload, sig string) string {
+		return strings.TrimSuffix(payload, ".") + "." + sig
+	})
+	token, err := extractor(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if token != headerTokenOk {
+		t.Errorf("Token: %s, want %s", token, headerTokenOk)
+	}
+}
+
+func TestFromSplitEmpty(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	token, err := jaywt.FromSplit(cookieExtractor("payload"), jaywt.FromAuthHeader, nil)(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if token != "" {
+		t.Errorf("Got %s, expected empty string", token)
+	}
+}
+
+func TestFromSplitIncomplete(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+splitSignature)
+
+	_, err := jaywt.FromSplit(cookieExtractor("payload"), jaywt.FromAuthHeader, nil)(req)
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestFromSplitError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "payload", Value: splitPayload})
+
+	_, err := jaywt.FromSplit(cookieExtractor("payload"), badExtractor, nil)(req)
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestFromFirstOk(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", headerOk)
+
+	token, err := jaywt.FromFirst(cookieExtractor("token"), jaywt.FromAuthHeader)(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if token != headerTokenOk {
+		t.Errorf("Token: %s, want %s", token, headerTokenOk)
+	}
+}
+
+func TestFromFirstOrder(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: "token", Value: "fromCookie"})
+	req.Header.Set("Authorization", headerOk)
+
+	token, err := jaywt.FromFirst(cookieExtractor("token"), jaywt.FromAuthHeader)(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if token != "fromCookie" {
+		t.Errorf("Token: %s, want %s", token, "fromCookie")
+	}
+}
+
+func TestFromFirstError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", headerOk)
+
+	_, err := jaywt.FromFirst(badExtractor, jaywt.FromAuthHeader)(req)
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestFromFirstNoCredentials(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:   sampleKeyfunc,
+		Extractor: jaywt.FromFirst(cookieExtractor("token"), jaywt.FromAuthHeader),
+	})
+
+	_, err := p.Get(req)
+	if err != jaywt.ErrNoCredentials {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrNoCredentials)
+	}
+}
+
+func TestFromFirstNoCredentialsWrapped(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Extractor: func(r *http.Request) (string, error) {
+			if _, err := jaywt.FromFirst(cookieExtractor("token"))(r); err != nil {
+				return "", fmt.Errorf("gateway: %w", err)
+			}
+
+			return "", nil
+		},
+	})
+
+	// The error isn't reported as a failing extractor
+	_, err := p.Get(req)
+	if !errors.Is(err, jaywt.ErrNoCredentials) || strings.HasPrefix(err.Error(), "Error extracting token") {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrNoCredentials)
+	}
+}
+
+func TestConfigExtractor(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"aud": "api"})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:  sampleKeyfunc,
+		Audience: "api",
+		ConfigExtractor: func(r *http.Request, o *jaywt.Options) (string, error) {
+			if o.Audience != "api" {
+				t.Errorf("Audience is %s, want api", o.Audience)
+			}
+
+			return jaywt.FromAuthHeader(r)
+		},
+	})
+
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestAdaptExtractor(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", headerOk)
+
+	token, err := jaywt.AdaptExtractor(jaywt.FromAuthHeader)(req, &jaywt.Options{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if token != headerTokenOk {
+		t.Errorf("Token: %s, want %s", token, headerTokenOk)
+	}
+}
+
+func TestFromTrailerOk(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+	req.Trailer = http.Header{}
+	req.Trailer.Set("X-Token", headerTokenOk)
+
+	token, err := jaywt.FromTrailer("X-Token")(req)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if token != headerTokenOk {
+		t.Errorf("Got %s, want %s", token, headerTokenOk)
+	}
+}
+
+func TestFromTrailerEmpty(t *testing.T) {
+	req := httptest.NewRequest(http.MethodPost, "/", nil)
+
+	token, err := jaywt.FromTrailer("X-Token")(req)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if token != "" {
+		t.Errorf("Got %s, want empty string", token)
+	}
+}
+
+var delimitedHeaderTable = []struct {
+	header string
+	index  int
+	want   string
+}{
+	{"user.token.a;service.token.b", 0, "user.token.a"},
+	{"user.token.a;service.token.b", 1, "service.token.b"},
+	{"user.token.a; service.token.b ", 1, "service.token.b"},
+	{"", 1, ""},
+}
+
+func TestFromDelimitedHeader(t *testing.T) {
+	for _, c := range delimitedHeaderTable {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("X-Tokens", c.header)
+
+		token, err := jaywt.FromDelimitedHeader("X-Tokens", ";", c.index)(req)
+		if err != nil {
+			t.Errorf("%q: %v", c.header, err)
+		}
+
+		if token != c.want {
+			t.Errorf("%q at %d: Got %s, want %s", c.header, c.index, token, c.want)
+		}
+	}
+}
+
+func TestFromDelimitedHeaderBad(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Tokens", "user.token.a")
+
+	for _, index := range []int{1, -1} {
+		if _, err := jaywt.FromDelimitedHeader("X-Tokens", ";", index)(req); err == nil {
+			t.Errorf("%d: Error was expected, got nil", index)
+		}
+	}
+}
+
+var sampleSources = []jaywt.NamedExtractor{
+	{Name: "header", Extractor: jaywt.FromAuthHeader},
+	{Name: "cookie", Extractor: cookieExtractor("token")},
+}
+
+func TestFromSourcesResult(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:   sampleKeyfunc,
+		Extractor: jaywt.FromSources(sampleSources...),
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+	res, err := p.GetResult(req, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Source != "header" {
+		t.Errorf("Got %q, want %q", res.Source, "header")
+	}
+
+	cookie := httptest.NewRequest(http.MethodGet, "/", nil)
+	cookie.AddCookie(&http.Cookie{Name: "token", Value: strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")})
+	if res, err = p.GetResult(cookie, jwt.MapClaims{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Source != "cookie" {
+		t.Errorf("Got %q, want %q", res.Source, "cookie")
+	}
+}
+
+func TestFromSourcesNoCredentials(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+
+	if _, err := jaywt.FromSources(sampleSources...)(req); err != jaywt.ErrNoCredentials {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrNoCredentials)
+	}
+}
+
+func TestFromSourcesError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	extractor := jaywt.FromSources(jaywt.NamedExtractor{Name: "bad", Extractor: badExtractor})
+
+	if _, err := extractor(req); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+func TestFromSourcesOutsideCheck(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", headerOk)
+
+	token, err := jaywt.FromSources(sampleSources...)(req)
+	if err != nil {
+		t.Error(err)
+	}
+
+	if token != headerTokenOk {
+		t.Errorf("Got %s, want %s", token, headerTokenOk)
+	}
+}
+
+func TestResultSourceDefault(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+	res, err := p.GetResult(req, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Source != "" {
+		t.Errorf("Got %q, want no source", res.Source)
+	}
+}
+
+func TestFromSourcesOtherCore(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+	get := func() {
+		if _, err := p.Get(req); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Using FromSources in one Core doesn't change how others check tokens
+	before := testing.AllocsPerRun(10, get)
+	jaywt.New(&jaywt.Options{
+		Keyfunc:   sampleKeyfunc,
+		Extractor: jaywt.FromSources(sampleSources...),
+	})
+
+	if after := testing.AllocsPerRun(10, get); after != before {
+		t.Errorf("Got %v allocations, want %v", after, before)
+	}
+}
+
+// Helper functions
+// ---
+
+func cookieExtractor(name string) jaywt.TokenExtractor {
+	return func(r *http.Request) (string, error) {
+		cookie, err := r.Cookie(name)
+		if err == http.ErrNoCookie {
+			return "", nil
+		}
+
+		return cookie.Value, err
+	}
+}
+
+func badExtractor(_ *http.Request) (string, error) {
+	return "", errors.New("Extractor error")
+}
@@ -0,0 +1,186 @@
+package jaywt
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"path"
+	"sync"
+)
+
+// IssuerConfig configures an issuer accepted by a Core from NewFederation.
+type IssuerConfig struct {
+	// Issuer is the issuer's 'iss' claim.
+	Issuer string
+	// URL of the issuer's JWKS. If empty, it is discovered from the issuer's
+	// OpenID Connect discovery document.
+	JWKSURL string
+	// Audiences accepted from the issuer. The token's 'aud' claim must
+	// contain one of them.
+	// Defaults to accepting any audience
+	Audiences []string
+}
+
+// NewFederation returns a new Core accepting tokens from all the configured
+// issuers, e.g. the identity providers of a product's customers. The token's
+// 'iss' claim selects the issuer, whose keys are fetched from its JWKS and
+// cached like with Options.JWKSURL, and whose audiences are enforced. Tokens
+// from other issuers fail with ErrInvalidIssuer, and tokens naming the key of
+// another issuer with ErrAmbiguousKey.
+//
+// Discovery documents are fetched right away, the keys on first use or by
+// Warm. SigningMethod is RS256, and the remaining options can be set on the
+// Core's Options, which the issuers share, e.g. HTTPClient or JWKSCache.
+func NewFederation(configs []IssuerConfig) (*Core, error) {
+	if len(configs) == 0 {
+		return nil, errors.New("No issuers were provided")
+	}
+
+	m := New(&Options{
+		SigningMethod: jwt.SigningMethodRS256,
+	})
+
+	issuers := make(map[string]*federatedIssuer, len(configs))
+	for _, c := range configs {
+		if c.Issuer == "" {
+			return nil, errors.New("Issuer must not be empty")
+		}
+
+		if _, ok := issuers[c.Issuer]; ok {
+			return nil, fmt.Errorf("Issuer '%s' is configured twice", c.Issuer)
+		}
+
+		url := c.JWKSURL
+		if url == "" {
+			config, err := discover(context.Background(), m.Options.HTTPClient, c.Issuer)
+			if err != nil {
+				return nil, fmt.Errorf("Error discovering issuer '%s': %w", c.Issuer, err)
+			}
+
+			url = config.JWKSURI
+		}
+
+		issuers[c.Issuer] = &federatedIssuer{
+			jwks:      &remoteJWKS{options: m.Options, url: url},
+			audiences: append([]string(nil), c.Audiences...),
+		}
+	}
+
+	m.issuers = issuers
+	m.Options.Keyfunc = m.federatedKeyfunc
+	return m, nil
+}
+
+// Helper functions
+// ---
+
+// federatedIssuer is an issuer configured by NewFederation.
+type federatedIssuer struct {
+	jwks      *remoteJWKS
+	audiences []string
+}
+
+// federatedKeyfunc selects the key from the JWKS of the token's issuer.
+func (m *Core) federatedKeyfunc(token *jwt.Token) (interface{}, error) {
+	claims, err := claimsMap(token)
+	if err != nil {
+		return nil, err
+	}
+
+	iss, _ := claims["iss"].(string)
+	issuer, ok := m.issuers[iss]
+	if !ok {
+		return nil, ErrInvalidIssuer
+	}
+
+	key, err := issuer.jwks.keyfunc(token)
+	if errors.Is(err, ErrUnknownKID) {
+		kid, _ := token.Header["kid"].(string)
+		for other, issuer := range m.issuers {
+			if other != iss && issuer.jwks.has(kid) {
+				return nil, ErrAmbiguousKey
+			}
+		}
+	}
+
+	return key, err
+}
+
+// checkFederatedAudience requires one of the audiences configured for the
+// token's issuer in the 'aud' claim.
+func (m *Core) checkFederatedAudience(claims jwt.MapClaims) error {
+	iss, _ := claims["iss"].(string)
+	issuer, ok := m.issuers[iss]
+	if !ok || len(issuer.audiences) == 0 {
+		return nil
+	}
+
+	for _, aud := range issuer.audiences {
+		if checkAudience(claims, aud, m.Options.NormalizeURLClaims) == nil {
+			return nil
+		}
+	}
+
+	return ErrInvalidAudience
+}
+
+// derivedJWKS keeps the keys of the issuers whose JWKS URL is derived by
+// Options.JWKSURLFromIssuer.
+type derivedJWKS struct {
+	mu   sync.Mutex
+	sets map[string]*remoteJWKS
+}
+
+// derivedKeyfunc selects the key from the JWKS derived from the token's
+// issuer.
+func (m *Core) derivedKeyfunc(token *jwt.Token) (interface{}, error) {
+	claims, err := claimsMap(token)
+	if err != nil {
+		return nil, err
+	}
+
+	iss, _ := claims["iss"].(string)
+	if !m.trustedIssuer(iss) {
+		return nil, ErrUntrustedIssuer
+	}
+
+	m.derived.mu.Lock()
+	set, ok := m.derived.sets[iss]
+	if !ok {
+		url := m.Options.JWKSURLFromIssuer(iss)
+		if url == "" {
+			m.derived.mu.Unlock()
+			return nil, ErrUntrustedIssuer
+		}
+
+		set = &remoteJWKS{options: m.Options, url: url}
+		if m.derived.sets == nil {
+			m.derived.sets = make(map[string]*remoteJWKS)
+		}
+
+		m.derived.sets[iss] = set
+	}
+	m.derived.mu.Unlock()
+
+	return set.keyfunc(token)
+}
+
+// trustedIssuer reports whether the issuer matches Options.TrustedIssuers.
+func (m *Core) trustedIssuer(iss string) bool {
+	if iss == "" {
+		return false
+	}
+
+	if len(m.Options.TrustedIssuers) == 0 {
+		return true
+	}
+
+	for _, pattern := range m.Options.TrustedIssuers {
+		if ok, _ := path.Match(pattern, iss); ok {
+			return true
+		}
+	}
+
+	return false
+}
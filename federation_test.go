@@ -0,0 +1,217 @@
+package jaywt_test
+
+import (
+	"context"
+	"crypto/rsa"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const sampleFederatedIssuer = "https://customer.example.com"
+
+func TestNewFederation(t *testing.T) {
+	jwksServer, _ := sampleJWKSServer(t, http.StatusOK)
+	defer jwksServer.Close()
+
+	oidcServer := sampleOIDCServer(t, []string{"RS256"}, "")
+	defer oidcServer.Close()
+
+	p, err := jaywt.NewFederation([]jaywt.IssuerConfig{
+		{Issuer: sampleFederatedIssuer, JWKSURL: jwksServer.URL, Audiences: []string{"api", "admin"}},
+		{Issuer: oidcServer.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey := mustGenerateRSAKey()
+	table := []struct {
+		key *rsa.PrivateKey
+		iss string
+		aud string
+		err error
+	}{
+		{sampleRSAKey, sampleFederatedIssuer, "api", nil},
+		{sampleRSAKey, sampleFederatedIssuer, "admin", nil},
+		{sampleRSAKey, sampleFederatedIssuer, "web", jaywt.ErrInvalidAudience},
+		{sampleRSAKey, oidcServer.URL, "web", nil},
+		{sampleRSAKey, "https://evil.example.com", "api", jaywt.ErrInvalidIssuer},
+	}
+
+	for _, c := range table {
+		_, err := p.Get(federatedRequest(t, c.key, c.iss, c.aud))
+		if err != c.err {
+			t.Errorf("%s %s: Got %v, want %v", c.iss, c.aud, err, c.err)
+		}
+	}
+
+	if _, err = p.Get(federatedRequest(t, otherKey, sampleFederatedIssuer, "api")); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+func TestNewFederationWarm(t *testing.T) {
+	server, hits := sampleJWKSServer(t, http.StatusOK)
+	defer server.Close()
+
+	p, err := jaywt.NewFederation([]jaywt.IssuerConfig{
+		{Issuer: sampleFederatedIssuer, JWKSURL: server.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if *hits != 0 {
+		t.Errorf("JWKS fetches: Got %d, want 0", *hits)
+	}
+
+	if err = p.Warm(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err = p.Get(federatedRequest(t, sampleRSAKey, sampleFederatedIssuer, "api")); err != nil {
+		t.Error(err)
+	}
+
+	if *hits != 1 {
+		t.Errorf("JWKS fetches: Got %d, want 1", *hits)
+	}
+}
+
+func TestNewFederationSharedOptions(t *testing.T) {
+	server, _ := sampleJWKSServer(t, http.StatusOK)
+	defer server.Close()
+
+	p, err := jaywt.NewFederation([]jaywt.IssuerConfig{
+		{Issuer: sampleFederatedIssuer, JWKSURL: server.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Options set after construction apply to the issuers
+	cache := &mapJWKSCache{entries: map[string][]byte{}}
+	p.Options.JWKSCache = cache
+	p.Options.JWKSRefresh = 10 * time.Minute
+	if err = p.Warm(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if cache.entries[server.URL] == nil {
+		t.Error("JWKS should be cached in the shared JWKSCache")
+	}
+
+	if cache.ttl != 10*time.Minute {
+		t.Errorf("TTL: Got %v, want %v", cache.ttl, 10*time.Minute)
+	}
+}
+
+func TestNewFederationBad(t *testing.T) {
+	server := sampleOIDCServer(t, []string{"RS256"}, "https://other.example.com")
+	defer server.Close()
+
+	table := [][]jaywt.IssuerConfig{
+		nil,
+		{{JWKSURL: "https://example.com/jwks"}},
+		{
+			{Issuer: sampleFederatedIssuer, JWKSURL: "https://example.com/jwks"},
+			{Issuer: sampleFederatedIssuer, JWKSURL: "https://example.com/jwks"},
+		},
+		{{Issuer: server.URL}},
+	}
+
+	for _, configs := range table {
+		if _, err := jaywt.NewFederation(configs); err == nil {
+			t.Errorf("%v: Error was expected, got nil", configs)
+		}
+	}
+}
+
+func TestNewFederationAmbiguousKey(t *testing.T) {
+	server, _ := sampleJWKSServer(t, http.StatusOK)
+	defer server.Close()
+
+	otherKey := mustGenerateRSAKey()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(sampleJWKS(t, rsaJWK("key-other", &otherKey.PublicKey)))
+	}))
+	defer other.Close()
+
+	p, err := jaywt.NewFederation([]jaywt.IssuerConfig{
+		{Issuer: sampleFederatedIssuer, JWKSURL: server.URL},
+		{Issuer: "https://other.example.com", JWKSURL: other.URL},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err = p.Warm(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The token claims the first issuer, but names the other one's key
+	req := sampleRequest(t, jwt.SigningMethodRS256, jwt.MapClaims{"iss": sampleFederatedIssuer}, withKey(otherKey), withHeader("kid", "key-other"))
+	if _, err = p.Get(req); err != jaywt.ErrAmbiguousKey {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrAmbiguousKey)
+	}
+}
+
+func TestJWKSURLFromIssuer(t *testing.T) {
+	server, hits := sampleJWKSServer(t, http.StatusOK)
+	defer server.Close()
+
+	var derived []string
+	p := jaywt.New(&jaywt.Options{
+		SigningMethod: jwt.SigningMethodRS256,
+		JWKSURLFromIssuer: func(iss string) string {
+			derived = append(derived, iss)
+			return iss + "/.well-known/jwks.json"
+		},
+		TrustedIssuers: []string{"http://127.0.0.1:*"},
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.Get(federatedRequest(t, sampleRSAKey, server.URL, "api")); err != nil {
+			t.Error(err)
+		}
+	}
+
+	for _, iss := range []string{"https://evil.example.com", "", server.URL + "/other"} {
+		if _, err := p.Get(federatedRequest(t, sampleRSAKey, iss, "api")); err != jaywt.ErrUntrustedIssuer {
+			t.Errorf("%q: Got %v, want %v", iss, err, jaywt.ErrUntrustedIssuer)
+		}
+	}
+
+	if *hits != 1 {
+		t.Errorf("JWKS fetches: Got %d, want 1", *hits)
+	}
+
+	if len(derived) != 1 {
+		t.Errorf("Got %v, want the URL derived once", derived)
+	}
+}
+
+func TestJWKSURLFromIssuerUntrusted(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		SigningMethod: jwt.SigningMethodRS256,
+		JWKSURLFromIssuer: func(iss string) string {
+			return ""
+		},
+	})
+
+	if _, err := p.Get(federatedRequest(t, sampleRSAKey, sampleFederatedIssuer, "api")); err != jaywt.ErrUntrustedIssuer {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrUntrustedIssuer)
+	}
+}
+
+// Helper functions
+// ---
+
+func federatedRequest(t *testing.T, key *rsa.PrivateKey, iss, aud string) *http.Request {
+	claims := jwt.MapClaims{"sub": sampleSubject, "iss": iss, "aud": aud}
+	return sampleRequest(t, jwt.SigningMethodRS256, claims, withKey(key), withHeader("kid", sampleKID))
+}
@@ -0,0 +1,19 @@
+package jaywt
+
+import (
+	"fmt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+)
+
+// GetWithRequestSignature verifies the request's signature using the verifier,
+// e.g. HTTP Message Signatures (RFC 9421), and only then extracts and
+// validates the JWT token from the request. The token isn't looked at if the
+// signature is invalid. It returns the parsed token, if successful.
+func (m *Core) GetWithRequestSignature(r *http.Request, verifier func(*http.Request) error) (*jwt.Token, error) {
+	if err := verifier(r); err != nil {
+		return nil, fmt.Errorf("Error verifying request signature: %w", err)
+	}
+
+	return m.Get(r)
+}
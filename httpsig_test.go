@@ -0,0 +1,69 @@
+package jaywt_test
+
+import (
+	"errors"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"testing"
+)
+
+var errBadSignature = errors.New("Signature is invalid")
+
+func TestGetWithRequestSignatureOk(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+
+	verified := false
+	token, err := p.GetWithRequestSignature(req, func(_ *http.Request) error {
+		verified = true
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !verified {
+		t.Error("Verifier should be called")
+	}
+
+	if sub := token.Claims.(jwt.MapClaims)["sub"]; sub != sampleSubject {
+		t.Errorf("Got %v, want %s", sub, sampleSubject)
+	}
+}
+
+func TestGetWithRequestSignatureBad(t *testing.T) {
+	extracted := false
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Extractor: func(r *http.Request) (string, error) {
+			extracted = true
+			return jaywt.FromAuthHeader(r)
+		},
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+
+	_, err := p.GetWithRequestSignature(req, func(_ *http.Request) error {
+		return errBadSignature
+	})
+	if !errors.Is(err, errBadSignature) {
+		t.Errorf("Got %v, want %v", err, errBadSignature)
+	}
+
+	if extracted {
+		t.Error("Token should not be extracted when the signature is invalid")
+	}
+}
+
+func TestGetWithRequestSignatureBadToken(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: badKeyfunc,
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+
+	if _, err := p.GetWithRequestSignature(req, func(_ *http.Request) error { return nil }); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
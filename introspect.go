@@ -0,0 +1,103 @@
+package jaywt
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"sync"
+	"time"
+)
+
+// Helper functions
+// ---
+
+// introspect returns the opaque token with the claims from
+// Options.Introspector, reusing them until the token expires.
+func (m *Core) introspect(ctx context.Context, raw string) (*jwt.Token, error) {
+	claims, ok := m.introspected.get(raw)
+	if !ok {
+		if err := contextError(ctx); err != nil {
+			return nil, err
+		}
+
+		var err error
+		if claims, err = m.Options.Introspector(ctx, raw); err != nil {
+			return nil, fmt.Errorf("Error introspecting token: %w", err)
+		}
+
+		if active, _ := claims["active"].(bool); !active {
+			return nil, errors.New("Token is not active")
+		}
+
+		if exp, ok := numericClaim(claims, "exp"); ok {
+			m.introspected.set(raw, claims, time.Unix(exp, 0))
+		}
+	}
+
+	if err := claims.Valid(); err != nil {
+		return nil, parseError(err)
+	}
+
+	return &jwt.Token{
+		Raw:    raw,
+		Header: map[string]interface{}{},
+		Claims: claims,
+		Valid:  true,
+	}, nil
+}
+
+// introspectionCache keeps the claims of introspected tokens until they
+// expire, keyed by the tokens' digests.
+type introspectionCache struct {
+	mu      sync.Mutex
+	entries map[[sha256.Size]byte]introspection
+}
+
+type introspection struct {
+	claims jwt.MapClaims
+	exp    time.Time
+}
+
+// get returns a copy of the token's cached claims, if they're still valid.
+func (c *introspectionCache) get(raw string) (jwt.MapClaims, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry, ok := c.entries[sha256.Sum256([]byte(raw))]
+	if !ok || time.Now().After(entry.exp) {
+		return nil, false
+	}
+
+	claims := make(jwt.MapClaims, len(entry.claims))
+	for k, v := range entry.claims {
+		claims[k] = v
+	}
+
+	return claims, true
+}
+
+// set caches a copy of the token's claims until exp, evicting expired ones.
+func (c *introspectionCache) set(raw string, claims jwt.MapClaims, exp time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range c.entries {
+		if now.After(entry.exp) {
+			delete(c.entries, k)
+		}
+	}
+
+	if c.entries == nil {
+		c.entries = make(map[[sha256.Size]byte]introspection)
+	}
+
+	entry := introspection{claims: make(jwt.MapClaims, len(claims)), exp: exp}
+	for k, v := range claims {
+		entry.claims[k] = v
+	}
+
+	c.entries[sha256.Sum256([]byte(raw))] = entry
+}
@@ -0,0 +1,132 @@
+package jaywt_test
+
+import (
+	"context"
+	"errors"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const sampleOpaqueToken = "2YotnFZFEjr1zCsicMWpAA"
+
+func TestGetIntrospector(t *testing.T) {
+	calls := 0
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Introspector: func(_ context.Context, raw string) (jwt.MapClaims, error) {
+			calls++
+			if raw != sampleOpaqueToken {
+				t.Errorf("Got %s, want %s", raw, sampleOpaqueToken)
+			}
+
+			return jwt.MapClaims{
+				"active": true,
+				"sub":    sampleSubject,
+				"exp":    float64(time.Now().Add(1 * time.Hour).Unix()),
+			}, nil
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		res, err := p.GetResult(opaqueRequest(sampleOpaqueToken), jwt.MapClaims{})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !res.Introspected || !res.Verified {
+			t.Errorf("Got %+v, want an introspected result", res)
+		}
+
+		if sub := res.Token.Claims.(jwt.MapClaims)["sub"]; sub != sampleSubject {
+			t.Errorf("Got %v, want %s", sub, sampleSubject)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("Introspector calls: Got %d, want 1", calls)
+	}
+
+	res, err := p.GetResult(sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject}), jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Introspected || calls != 1 {
+		t.Errorf("Got %d introspector calls, want JWTs to be parsed", calls)
+	}
+}
+
+func TestGetIntrospectorNoExp(t *testing.T) {
+	calls := 0
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Introspector: func(_ context.Context, _ string) (jwt.MapClaims, error) {
+			calls++
+			return jwt.MapClaims{"active": true}, nil
+		},
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.Get(opaqueRequest(sampleOpaqueToken)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("Introspector calls: Got %d, want 2", calls)
+	}
+}
+
+var introspectorTableBad = []struct {
+	claims jwt.MapClaims
+	err    error
+}{
+	{jwt.MapClaims{"active": false}, nil},
+	{jwt.MapClaims{"aud": "api"}, nil},
+	{jwt.MapClaims{"active": "true", "aud": "api"}, nil},
+	{jwt.MapClaims{"active": true, "aud": "web"}, jaywt.ErrInvalidAudience},
+	{jwt.MapClaims{"active": true, "aud": "api", "exp": float64(time.Now().Add(-1 * time.Hour).Unix())}, jaywt.ErrTokenExpired},
+	{nil, nil},
+}
+
+func TestGetIntrospectorBad(t *testing.T) {
+	for _, c := range introspectorTableBad {
+		p := jaywt.New(&jaywt.Options{
+			Keyfunc:  sampleKeyfunc,
+			Audience: "api",
+			Introspector: func(_ context.Context, _ string) (jwt.MapClaims, error) {
+				if c.claims == nil {
+					return nil, errors.New("Introspection endpoint is down")
+				}
+
+				return c.claims, nil
+			},
+		})
+
+		_, err := p.Get(opaqueRequest(sampleOpaqueToken))
+		if err == nil || c.err != nil && !errors.Is(err, c.err) {
+			t.Errorf("%v: Got %v, want %v", c.claims, err, c.err)
+		}
+	}
+
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	if _, err := p.Get(opaqueRequest(sampleOpaqueToken)); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+// Helper functions
+// ---
+
+func opaqueRequest(raw string) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+raw)
+	return req
+}
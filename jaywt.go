@@ -1,16 +1,39 @@
 package jaywt
 
 import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"gopkg.in/dgrijalva/jwt-go.v3"
+	"log/slog"
 	"net/http"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 // TokenExtractor is a function retrieving the raw token string from a request.
 type TokenExtractor func(r *http.Request) (string, error)
 
+// ConfigExtractor is a TokenExtractor that also receives the Core's options,
+// so it can adapt the extraction to the configuration.
+type ConfigExtractor func(r *http.Request, o *Options) (string, error)
+
+// AdaptExtractor turns a TokenExtractor into a ConfigExtractor that doesn't
+// look at the options.
+func AdaptExtractor(e TokenExtractor) ConfigExtractor {
+	return func(r *http.Request, _ *Options) (string, error) {
+		return e(r)
+	}
+}
+
 // Options determine the behavior of the checking functions.
 type Options struct {
 	// Function that will return the Key to the JWT, public key or shared secret.
@@ -19,16 +42,450 @@ type Options struct {
 	// Function that will extract the JWT from the request.
 	// Defaults to 'Authorization' header being of the form 'Bearer <token>'
 	Extractor TokenExtractor
+	// Function that will extract the JWT from the request, given the options.
+	// Takes precedence over Extractor when set.
+	// Defaults to nil
+	ConfigExtractor ConfigExtractor
 	// Which algorithm to use.
 	// Defaults to jwt.SigningMethodHS256
 	SigningMethod jwt.SigningMethod
+	// Names of the algorithms the parser accepts, rejecting others before
+	// selecting the key. When set, it takes precedence over SigningMethod,
+	// which is then only checked when ValidMethods is empty.
+	// Defaults to nil, meaning only SigningMethod
+	ValidMethods []string
+	// Whether tokens without an 'exp' claim are rejected with ErrTokenExpired,
+	// the same as tokens that are already expired.
+	// Defaults to false
+	TreatNoExpAsExpired bool
+	// Whether GetResult falls back to reading the token without verifying its
+	// signature when the Keyfunc fails with ErrKeyUnavailable.
+	//
+	// WARNING: this accepts tokens anyone could have forged. Only enable it
+	// for internal endpoints, and always check Result.Verified.
+	// Get and GetWithClaims are not affected and keep failing.
+	// Defaults to false
+	DegradedMode bool
+	// Function that normalizes the claims after the token has been validated,
+	// e.g. to rename issuer-specific namespaced claims. Its result replaces the
+	// token's claims. Only applies to claims of the jwt.MapClaims type.
+	// Defaults to nil
+	ClaimTransform func(jwt.MapClaims) jwt.MapClaims
+	// Key that Handler stores the token under in the request context.
+	// Defaults to the package's own key, used by FromContext
+	ContextKey interface{}
+	// Maximum size of the decoded header and claims JSON in bytes, each.
+	// Tokens with a larger segment are rejected with ErrClaimsTooLarge before
+	// anything is decoded. With a limit, the JSON is also strict: a segment
+	// with data after its object, which jwt-go would ignore, is malformed.
+	// It also bounds the bodies VerifyWebhook reads.
+	// Defaults to 0, meaning no limit, except 1 MiB for VerifyWebhook
+	MaxClaimsBytes int
+	// Key used to sign refreshed tokens with SigningMethod, e.g. the shared
+	// secret or a private key.
+	// Defaults to nil
+	SignKey interface{}
+	// RefreshHandler refreshes tokens expiring sooner than this.
+	// Defaults to 0, disabling refreshing
+	RefreshThreshold time.Duration
+	// Lifetime of tokens issued by RefreshHandler.
+	// Defaults to 0, disabling refreshing
+	RefreshTTL time.Duration
+	// Template of the cookie RefreshHandler sets the refreshed token in.
+	// Its Value and Expires fields are overwritten.
+	// Defaults to nil, disabling refreshing
+	RefreshCookie *http.Cookie
+	// Function that makes the middlewares skip token processing for the
+	// request, e.g. health checks, when it returns true.
+	// Defaults to nil, processing every request
+	Skipper func(r *http.Request) bool
+	// Audience the token's 'aud' claim must contain.
+	// Defaults to "", meaning any audience
+	Audience string
+	// Issuer the token's 'iss' claim must be equal to.
+	// Defaults to "", meaning any issuer
+	Issuer string
+	// Allowed clock skew when checking the 'exp', 'nbf' and 'iat' claims.
+	// Tokens passing only thanks to it are logged at the info level and
+	// flagged by Result.LeewayAccepted, to reveal clock drift.
+	// Defaults to 0
+	Leeway time.Duration
+	// Function the handlers call to respond when the token check fails.
+	// Defaults to responding with 403 Forbidden to authorization errors, as
+	// reported by IsAuthorizationError, and 401 Unauthorized to others
+	ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+	// Authentication context levels, weakest first, used by RequireACR to
+	// compare 'acr' claims. Without it, the 'acr' must match exactly.
+	// Defaults to nil
+	ACRLevels []string
+	// Authentication methods rejected in the 'amr' claim, e.g. deprecated
+	// "sms" MFA. Tokens listing any of them fail with ErrDisallowedAMR, and
+	// tokens without the claim pass.
+	// Defaults to nil
+	DisallowedAMR []string
+	// Logger receiving structured logs of the token checks: debug logs for
+	// extracted tokens, selected keys and successes, and warnings for
+	// failures. Attribute keys are stable: "kid", "alg" and "error".
+	// Defaults to a logger discarding everything
+	Logger *slog.Logger
+	// Name of the top-level claim holding the actual claims, for issuers that
+	// nest them, e.g. "data". The nested object replaces the token's claims
+	// and is validated instead, including the 'exp', 'iss' and 'aud' claims.
+	// Tokens without it are rejected with ErrClaimsRootMissing.
+	// Defaults to "", meaning claims are at the top level
+	ClaimsRoot string
+	// Key IDs whose tokens are rejected with ErrRevokedKID, even if their
+	// signature is valid, e.g. after a key is compromised. Only read by New;
+	// use Core.SetRevokedKIDs to change the list at runtime.
+	// Defaults to nil
+	RevokedKIDs []string
+	// Function supplying the payload of detached JWS tokens (RFC 7797), whose
+	// payload segment is empty. The payload is base64url encoded into the
+	// token before verification, so Token.Raw holds the reconstructed token.
+	// Only the default "b64": true encoding is supported; tokens with the
+	// "b64": false header are rejected with ErrUnsupportedB64False.
+	// Defaults to nil, meaning detached tokens are rejected
+	DetachedPayload func(r *http.Request) ([]byte, error)
+	// Whether to reject requests not made over TLS with ErrInsecureTransport,
+	// before extracting the token.
+	// Defaults to false
+	RequireTLS bool
+	// Whether RequireTLS and GetWithDPoP trust the X-Forwarded-Proto header
+	// set by a proxy terminating TLS. Only enable it behind a proxy
+	// overwriting the header.
+	// Defaults to false
+	TrustForwardedProto bool
+	// Separator of the scopes in the 'scope' claim, used by Scopes and
+	// HasScope, e.g. "," for issuers emitting comma-separated scopes.
+	// Defaults to " "
+	ScopeSeparator string
+	// URL of the JWKS document to select keys from when Keyfunc is nil. Keys
+	// are fetched on first use, or by Core.Warm, and refetched every
+	// JWKSRefresh. When a refetch fails, the previous keys are kept.
+	// Defaults to ""
+	JWKSURL string
+	// How long the keys fetched from JWKSURL are used before refetching.
+	// Defaults to 1 hour
+	JWKSRefresh time.Duration
+	// Minimum time between refetches of JWKSURL forced by tokens with an
+	// unknown key ID, which are rejected with ErrUnknownKID if it's still
+	// unknown. It limits the refetches tokens with made up key IDs cause.
+	// Defaults to 1 minute
+	JWKSForcedRefresh time.Duration
+	// Root CAs to verify the 'x5c' certificate chains of keys fetched from
+	// JWKSURL against.
+	// Defaults to nil, meaning chains are not verified
+	RootCAs *x509.CertPool
+	// Client used to fetch JWKSURL.
+	// Defaults to http.DefaultClient
+	HTTPClient *http.Client
+	// Cache of the JWKS documents fetched from JWKSURL, read before fetching
+	// and written after, for JWKSRefresh. Set it to share the documents
+	// between instances.
+	// Defaults to an in-memory cache
+	JWKSCache JWKSCache
+	// Functions validating the claims of the given names, e.g. checking a
+	// 'tenant_id' is a non-empty string. They receive nil for missing claims.
+	// Their errors fail the check with ErrClaimInvalid naming the claim.
+	// Defaults to nil
+	ClaimValidators map[string]func(value interface{}) error
+	// Maximum age of tokens, measured from their 'iat' claim regardless of
+	// 'exp', e.g. for re-authentication before sensitive operations. Older
+	// tokens and tokens without 'iat' are rejected with ErrTokenTooOld.
+	// Defaults to 0, meaning no maximum
+	MaxAge time.Duration
+	// Names of the critical header extensions, listed in the 'crit' header,
+	// the application understands. Tokens using others are rejected with
+	// ErrUnsupportedCritical, as RFC 7515 requires. "b64" is always known.
+	// Defaults to nil
+	KnownCritical []string
+	// Additional validation stages, run in order after the built-in checks
+	// and ClaimTransform. The first error fails the check. Validators
+	// replicating the built-in checks, e.g. AudienceValidator, can be combined
+	// with custom ones.
+	// Defaults to nil
+	Validators []Validator
+	// Store of consumed 'jti' claims, making tokens single-use. It is checked
+	// after all other validation, including Deny, so rejected tokens aren't
+	// consumed. Replayed tokens are rejected with ErrTokenReplay, as are
+	// tokens without 'jti'.
+	// Defaults to nil, meaning tokens can be reused
+	ReplayStore ReplayStore
+	// How long after expiring tokens are still accepted, e.g. while migrating
+	// signing keys. Unlike Leeway, which allows for clock skew, it knowingly
+	// accepts expired tokens, flagging them by Result.Expired.
+	// Defaults to 0, meaning expired tokens are rejected
+	ExpiredGrace time.Duration
+	// Function called with the outcome of every token check made by the
+	// checking functions and handlers, e.g. for metrics. The result is nil if
+	// the check failed.
+	// Defaults to nil
+	OnValidate func(r *http.Request, res *Result, err error)
+	// Function decrypting signed-then-encrypted tokens, returning the inner
+	// signed token of the compact JWE. It's called for tokens with the five
+	// JWE segments, and the inner token is then checked as usual. Failures
+	// are reported with ErrDecryptionFailed, distinct from the errors of
+	// checking the inner token. Tokens that aren't encrypted are checked as
+	// they are.
+	// Defaults to nil, meaning encrypted tokens are rejected
+	NestedDecrypt func(jwe string) (string, error)
+	// Audiences the token's 'aud' claim must contain, by the prefix of the
+	// request's path, e.g. "/billing/". The longest matching prefix wins, and
+	// requests matching none require Audience.
+	// Defaults to nil
+	AudienceByPrefix map[string]string
+	// How long the previous secret is still accepted after Core.SetHMACSecret
+	// rotates it, so tokens signed before the rotation keep working.
+	// Defaults to 0, meaning it's rejected right away
+	HMACOverlap time.Duration
+	// Whether to compare the 'iss' claim and URL-valued 'aud' claims with
+	// Issuer and Audience loosely, ignoring trailing slashes and the case of
+	// the scheme and host, e.g. "https://IdP.example.com/" is then
+	// "https://idp.example.com". Values that aren't URLs are compared as usual.
+	// Defaults to false, meaning exact comparison
+	NormalizeURLClaims bool
+	// Prefix wrapping the extracted token, e.g. "v2." for tokens versioned by
+	// a gateway. It is stripped before parsing, and tokens without it fail
+	// with ErrBadPrefix.
+	// Defaults to "", meaning no prefix
+	TokenPrefix string
+	// Function called with an AuditEvent for every request the token is
+	// accepted for by Get and the other checking functions, e.g. to keep
+	// a compliance log. Unlike OnValidate, it isn't called on failures.
+	// Defaults to nil, meaning no auditing
+	AuditFunc func(AuditEvent)
+	// Function returning data the token's signature is bound to, e.g. the
+	// request method and path for operation-bound tokens. The signature must
+	// then be over the token's 'header.payload' followed by the data, which
+	// departs from plain JWT. Tokens not bound to the data fail with
+	// ErrAssociatedDataMismatch, as do all tokens checked without a request.
+	// Defaults to nil, meaning plain JWT signatures
+	AssociatedData func(r *http.Request) []byte
+	// Whether to measure how long the phases of the check take, reported in
+	// Result.Timings, e.g. to attribute latency to slow JWKS fetches.
+	// Defaults to false, sparing the clock reads
+	Timings bool
+	// Whether to count the outcomes of checks by category, reported by
+	// Stats, e.g. for a dashboard of why tokens are rejected.
+	// Defaults to false
+	TrackStats bool
+	// Function introspecting opaque tokens at the issuer, e.g. by RFC 7662
+	// token introspection. Tokens that aren't JWTs are passed to it, and the
+	// returned claims are trusted like those of a verified token. They must
+	// have the 'active' claim set to true, which RFC 7662 requires, or the
+	// token is rejected. The claims are then jwt.MapClaims, aren't descended
+	// into with ClaimsRoot, and are cached until their 'exp'.
+	// Defaults to nil, meaning opaque tokens are rejected
+	Introspector func(ctx context.Context, raw string) (jwt.MapClaims, error)
+	// Function decoding the JSON of headers and claims the package decodes
+	// itself, e.g. for ClaimsRoot, DetachedPayload or GetDebug, to control
+	// number handling and strictness. Parsing by jwt-go still uses
+	// encoding/json.
+	// Defaults to json.Unmarshal
+	JSONUnmarshal func(data []byte, v interface{}) error
+	// Function called with the 'sub' and 'jti' claims of every verified
+	// token accepted by Get and the other checking functions, e.g. to bump
+	// the last-seen time of a server-side session. Its errors are logged.
+	// Defaults to nil, meaning no sessions
+	SessionTouch func(ctx context.Context, sub, jti string) error
+	// Whether errors of SessionTouch fail the check, instead of only being
+	// logged.
+	// Defaults to false
+	FailOnSessionTouchError bool
+	// Function deriving the JWKS URL from the token's 'iss' claim, e.g.
+	// "{iss}/.well-known/jwks.json" for tenants with their own issuers, used
+	// when neither Keyfunc nor JWKSURL is set. The keys of each issuer are
+	// fetched on first use and cached like those of JWKSURL.
+	//
+	// WARNING: The 'iss' claim isn't verified yet, so restrict the issuers
+	// with TrustedIssuers, or return "" for untrusted ones, which fail with
+	// ErrUntrustedIssuer. Otherwise, anyone can sign tokens with their keys.
+	// Defaults to nil
+	JWKSURLFromIssuer func(iss string) string
+	// Patterns of issuers trusted by JWKSURLFromIssuer, in the syntax of
+	// path.Match, e.g. "https://*.idp.example.com". Tokens from other issuers
+	// fail with ErrUntrustedIssuer.
+	// Defaults to nil, meaning JWKSURLFromIssuer decides
+	TrustedIssuers []string
+	// Minimum modulus size in bits of RSA keys returned by the Keyfunc. Tokens
+	// verified by smaller keys fail with ErrWeakKey.
+	// Defaults to 2048
+	MinRSAKeySize int
+	// Minimum curve size in bits of ECDSA keys returned by the Keyfunc. Tokens
+	// verified by keys on smaller curves fail with ErrWeakKey.
+	// Defaults to 256
+	MinECKeySize int
+	// Cache of verified tokens, skipping the signature verification of their
+	// repeat presentations, e.g. of long-lived service tokens. The claims
+	// are still validated on each check. Tokens bound to AssociatedData
+	// aren't cached.
+	// Defaults to nil, meaning no cache
+	ValidationCache *ValidationCache
+	// IP addresses or CIDR ranges of the proxies trusted to set the
+	// X-Forwarded-For header, used by the default ClientIPFunc.
+	// Defaults to nil, meaning the header is ignored
+	TrustedProxies []string
+	// Function resolving the IP address of the client, e.g. for
+	// AuditEvent.ClientIP or rate limiting per client.
+	// Defaults to ForwardedClientIP(TrustedProxies)
+	ClientIPFunc func(r *http.Request) string
+	// Whether a blank token after the 'Bearer' scheme fails with
+	// ErrEmptyBearerToken, telling clients sending one apart from requests
+	// without a token. Otherwise, it fails like other extraction errors.
+	// Defaults to false
+	StrictEmptyToken bool
+	// Resolver of the keys verifying tokens, e.g. from a KMS or a database,
+	// taking precedence over Keyfunc. It is passed the request's context.
+	// Defaults to nil, meaning Keyfunc selects the keys
+	KeyResolver KeyResolver
+	// Audiences tokens must not be for, e.g. an admin audience on a service
+	// facing users. Tokens with any of them in the 'aud' claim fail with
+	// ErrForbiddenAudience, even if they also have the required Audience, as
+	// both checks must pass.
+	// Defaults to nil
+	ForbiddenAudiences []string
+	// Validation stages run after Validators in shadow mode, e.g. to measure
+	// the impact of a stricter policy before enforcing it. Their errors are
+	// collected in Result.DryRunErrors and logged, but never fail the check.
+	// Defaults to nil
+	DryRunValidators []Validator
+	// Expected value of the TokenUseClaim, e.g. "access" for endpoints that
+	// must reject refresh tokens. Tokens with another value, or without the
+	// claim, fail with ErrWrongTokenUse.
+	// Defaults to "", meaning any use
+	ExpectedTokenUse string
+	// Name of the claim checked against ExpectedTokenUse.
+	// Defaults to "token_use", as issued by AWS Cognito
+	TokenUseClaim string
+	// Header set by a trusted hop that already authenticated the request,
+	// e.g. a sidecar terminating mTLS. When its value is TrustedSkipValue,
+	// the signature isn't verified, only the claims are validated, and the
+	// result is flagged as Result.TrustedHop.
+	//
+	// WARNING: anyone setting the header can forge tokens. The edge proxy
+	// must strip it from external traffic, and TrustedSkipValue must be
+	// a secret.
+	// Defaults to "", meaning signatures are always verified
+	TrustedSkipHeader string
+	// Value of TrustedSkipHeader marking requests from the trusted hop. Both
+	// must be set.
+	// Defaults to ""
+	TrustedSkipValue string
+	// Whether jwt-go decodes numbers in MapClaims as json.Number instead of
+	// float64, so integer claims above 2^53, e.g. 64-bit user IDs, keep
+	// their precision. Read them with Int64Claim.
+	// Defaults to false
+	UseJSONNumber bool
+	// Media types accepted in the 'typ' header, compared case-insensitively
+	// and with or without the "application/" prefix, e.g. "JWT" and "at+jwt"
+	// for issuers typing access tokens differently. Tokens of other types
+	// fail with ErrInvalidType.
+	// Defaults to nil, meaning any type
+	AllowedTypes []string
+	// Whether tokens without the 'typ' header fail with ErrInvalidType.
+	// Defaults to false, meaning untyped tokens are accepted
+	RequireTyp bool
+	// Function selecting the previous key of a migration, e.g. an old RSA
+	// key still signing some tokens. Tokens whose signature doesn't match the
+	// key selected by Keyfunc or KeyResolver are verified again with it.
+	// Other failures, like expired or malformed tokens, aren't retried. If
+	// its key doesn't match the signature either, the original error is
+	// returned, otherwise the claims are validated as usual, with Leeway and
+	// ExpiredGrace.
+	// Defaults to nil, meaning no fallback
+	FallbackKeyfunc jwt.Keyfunc
+	// Policy run after every built-in check and Validators, but before
+	// ReplayStore marks the token used, e.g. for time-of-day restrictions or
+	// suspended tenants. Tokens it returns an error for are rejected with the
+	// error marked as Forbidden. The request is nil when validating with
+	// ValidateString.
+	// Defaults to nil
+	Deny func(claims jwt.MapClaims, r *http.Request) error
+	// Store of the 'jti' claims of the DPoP proofs accepted by GetWithDPoP,
+	// so each proof is used once. Unlike ReplayStore, it doesn't make the
+	// access tokens single-use. Share a store between instances.
+	// Defaults to an in-memory store
+	DPoPReplayStore ReplayStore
+	// Key ID of SignKey, set as the 'kid' header of refreshed tokens.
+	// Defaults to "", meaning refreshed tokens have no 'kid'
+	SignKeyID string
+	// How long Refresh extends a session for at most, measured from the
+	// token's 'auth_time' claim, or its 'iat' claim if it has none. Refreshed
+	// tokens keep the 'auth_time', and don't expire later than it allows.
+	// Defaults to 24 hours
+	RefreshMaxLifetime time.Duration
+}
+
+// Result is the outcome of a successful check made by GetResult.
+type Result struct {
+	// The parsed token.
+	Token *jwt.Token
+	// Whether the token's signature was verified. It can only be false
+	// when DegradedMode is on.
+	Verified bool
+	// The error that prevented signature verification, if Verified is false.
+	VerifyError error
+	// Whether the token's time claims only passed thanks to Options.Leeway.
+	LeewayAccepted bool
+	// Whether the token is expired, and only passed thanks to
+	// Options.ExpiredGrace.
+	Expired bool
+	// Name of the source the token was extracted from, if extracted by
+	// FromSources.
+	Source string
+	// Whether the token is opaque, and its claims are from
+	// Options.Introspector.
+	Introspected bool
+	// How long the phases of the check took, if Options.Timings is on.
+	Timings Timings
+	// The errors of Options.DryRunValidators, which didn't fail the check.
+	DryRunErrors []error
+	// Whether the request came through a trusted hop, see
+	// Options.TrustedSkipHeader, so the signature wasn't verified and
+	// Verified is false, yet the token is accepted by Get.
+	TrustedHop bool
+}
+
+// Timings are the durations of the phases of a check.
+type Timings struct {
+	// Extracting the token from the request.
+	Extraction time.Duration
+	// Selecting the key in the Keyfunc, including fetching it.
+	KeySelection time.Duration
+	// Parsing the token and verifying its signature, excluding KeySelection.
+	Verification time.Duration
+	// Validating the claims, including Validators and ReplayStore.
+	Validation time.Duration
 }
 
 // Core is the main structure which provides an interface for checking the token.
 type Core struct {
 	Options *Options
+
+	parser *jwt.Parser
+
+	mu      sync.RWMutex
+	revoked map[string]bool
+	hmac    hmacSecrets
+
+	jwks    *remoteJWKS
+	issuers map[string]*federatedIssuer
+
+	derived      derivedJWKS
+	introspected introspectionCache
+	stats        [statsCategories]atomic.Int64
+	kids         sync.Map
+
+	// id scopes the Core's entries in a shared ValidationCache.
+	id uint64
+	// sources is set if the Extractor is from FromSources, which reports the
+	// source.
+	sources bool
 }
 
+// coreIDs numbers the Cores for Core.id.
+var coreIDs atomic.Uint64
+
 // New returns a new Core with the given options.
 // It supplies default options for some fields (check Options type for details).
 func New(o *Options) *Core {
@@ -40,96 +497,1220 @@ func New(o *Options) *Core {
 		o.SigningMethod = jwt.SigningMethodHS256
 	}
 
-	return &Core{o}
+	if o.ContextKey == nil {
+		o.ContextKey = contextKey{}
+	}
+
+	if o.ErrorHandler == nil {
+		o.ErrorHandler = defaultErrorHandler
+	}
+
+	if o.Logger == nil {
+		o.Logger = slog.New(discardHandler{})
+	}
+
+	if o.ScopeSeparator == "" {
+		o.ScopeSeparator = " "
+	}
+
+	if o.JWKSRefresh == 0 {
+		o.JWKSRefresh = time.Hour
+	}
+
+	if o.JWKSForcedRefresh == 0 {
+		o.JWKSForcedRefresh = time.Minute
+	}
+
+	if o.HTTPClient == nil {
+		o.HTTPClient = http.DefaultClient
+	}
+
+	if o.JSONUnmarshal == nil {
+		o.JSONUnmarshal = json.Unmarshal
+	}
+
+	if o.TokenUseClaim == "" {
+		o.TokenUseClaim = "token_use"
+	}
+
+	if o.MinRSAKeySize == 0 {
+		o.MinRSAKeySize = 2048
+	}
+
+	if o.MinECKeySize == 0 {
+		o.MinECKeySize = 256
+	}
+
+	if o.ClientIPFunc == nil {
+		o.ClientIPFunc = ForwardedClientIP(o.TrustedProxies)
+	}
+
+	if o.JWKSCache == nil {
+		o.JWKSCache = &memoryJWKSCache{}
+	}
+
+	if o.DPoPReplayStore == nil {
+		o.DPoPReplayStore = NewMemoryReplayStore()
+	}
+
+	if o.RefreshMaxLifetime == 0 {
+		o.RefreshMaxLifetime = 24 * time.Hour
+	}
+
+	m := &Core{
+		Options: o,
+		parser:  &jwt.Parser{ValidMethods: o.ValidMethods, UseJSONNumber: o.UseJSONNumber},
+		id:      coreIDs.Add(1),
+		sources: o.ConfigExtractor == nil && reportsSource(o.Extractor),
+	}
+
+	if o.Keyfunc == nil && o.JWKSURL != "" {
+		m.jwks = &remoteJWKS{options: o}
+		o.Keyfunc = m.jwks.keyfunc
+	} else if o.Keyfunc == nil && o.JWKSURLFromIssuer != nil {
+		o.Keyfunc = m.derivedKeyfunc
+	}
+
+	m.SetRevokedKIDs(o.RevokedKIDs)
+	return m
+}
+
+// SetRevokedKIDs replaces the list of revoked key IDs. It is safe to call
+// while tokens are being checked.
+func (m *Core) SetRevokedKIDs(kids []string) {
+	revoked := make(map[string]bool, len(kids))
+	for _, kid := range kids {
+		revoked[kid] = true
+	}
+
+	m.mu.Lock()
+	m.revoked = revoked
+	m.mu.Unlock()
 }
 
 // FromAuthHeader is the default extractor. It expects the 'Authorization' header
 // to be in the form 'Bearer <token>'. If the header is non-existent or empty,
 // it returns an empty string. Otherwise, if successful, returns the token part.
+// Only the first space separates the scheme, and spaces surrounding the token
+// are dropped, but the token must not be empty, see ErrEmptyBearerToken.
 func FromAuthHeader(r *http.Request) (string, error) {
 	header := r.Header.Get("Authorization")
 	if header == "" {
 		return "", nil // No error, just no token
 	}
 
-	parts := strings.Split(header, " ")
-	if len(parts) != 2 || strings.ToLower(parts[0]) != "bearer" {
-		return "", fmt.Errorf("Authorization header format must be 'Bearer <token>'")
+	// Split at the first space, without allocating. Servers trim the header,
+	// so a lone scheme is a blank token too
+	i := strings.IndexByte(header, ' ')
+	if i < 0 {
+		i = len(header)
+	}
+
+	if !strings.EqualFold(header[:i], "bearer") {
+		return "", errors.New("Authorization header format must be 'Bearer <token>'")
 	}
 
-	return parts[1], nil
+	token := strings.TrimSpace(header[i:])
+	if token == "" {
+		return "", ErrEmptyBearerToken
+	}
+
+	return token, nil
 }
 
 // Get extracts and validates the JWT token from the request. It returns
 // the parsed token, if successful.
 func (m *Core) Get(r *http.Request) (*jwt.Token, error) {
-	// Extract token
-	raw, err := m.rawToken(r)
+	return m.getVerified(r, jwt.MapClaims{})
+}
+
+// GetWithClaims extracts and validates the JWT token from the request,
+// as well as the supplied claims. It returns the parsed token with the
+// supplied claims, if successful.
+func (m *Core) GetWithClaims(r *http.Request, claims jwt.Claims) (*jwt.Token, error) {
+	return m.getVerified(r, claims)
+}
+
+// GetResult extracts and validates the JWT token from the request, as well
+// as the supplied claims. It returns the Result of the check, if successful.
+//
+// This is the only function honoring DegradedMode, so the returned token
+// might be unverified. Check Result.Verified before trusting it.
+func (m *Core) GetResult(r *http.Request, claims jwt.Claims) (*Result, error) {
+	return m.check(r, claims)
+}
+
+// Extract extracts the raw JWT token from the request without validating it,
+// e.g. for logging in an early middleware. Pass it to ValidateRaw later.
+func (m *Core) Extract(r *http.Request) (string, error) {
+	return m.rawToken(r)
+}
+
+// ValidateRaw validates the raw token string returned by Extract. Validators
+// get a nil request, and options that need the request, such as RequireTLS,
+// DetachedPayload or AudienceByPrefix, have no effect. It returns the parsed
+// token, if successful.
+func (m *Core) ValidateRaw(raw string) (*jwt.Token, error) {
+	return m.validateRaw(context.Background(), raw)
+}
+
+// ValidateString validates the raw token string using a Core with the given
+// options, without an HTTP request, e.g. in command line tools. Options that
+// need the request, such as Extractor, RequireTLS or DetachedPayload, have no
+// effect. It returns the parsed token, if successful.
+func ValidateString(raw string, o *Options) (*jwt.Token, error) {
+	return New(o).ValidateRaw(raw)
+}
+
+// Helper functions
+// ---
+
+func (m *Core) validateRaw(ctx context.Context, raw string) (*jwt.Token, error) {
+	if raw == "" {
+		return nil, errTokenNotFound
+	}
+
+	res, err := m.validate(ctx, nil, raw, jwt.MapClaims{})
 	if err != nil {
 		return nil, err
 	}
 
+	if !res.Verified {
+		return nil, fmt.Errorf("Error parsing token: %w", res.VerifyError)
+	}
+
+	if err = m.consume(ctx, res); err != nil {
+		return nil, err
+	}
+
+	return res.Token, nil
+}
+
+// getVerified checks the token like Get, also validating it with the extra
+// validators, which run inside the check, so tokens they reject are neither
+// consumed nor reported as accepted.
+func (m *Core) getVerified(r *http.Request, claims jwt.Claims, extra ...Validator) (*jwt.Token, error) {
+	res, err := m.check(r, claims, extra...)
+	if err != nil {
+		return nil, err
+	}
+
+	return verifiedToken(res)
+}
+
+// verifiedToken returns the token of the result, unless it is unverified
+// and not from a trusted hop, e.g. in DegradedMode.
+func verifiedToken(res *Result) (*jwt.Token, error) {
+	if !res.Verified && !res.TrustedHop {
+		return nil, fmt.Errorf("Error parsing token: %w", res.VerifyError)
+	}
+
+	return res.Token, nil
+}
+
+func (m *Core) check(r *http.Request, claims jwt.Claims, extra ...Validator) (*Result, error) {
+	res, err := m.run(r, claims, extra...)
+	if m.Options.TrackStats {
+		m.stats[statsCategory(err)].Add(1)
+		if err == nil {
+			m.countKeyID(res)
+		}
+	}
+
+	if m.Options.OnValidate != nil {
+		m.Options.OnValidate(r, res, err)
+	}
+
+	if err != nil {
+		m.Options.Logger.LogAttrs(r.Context(), slog.LevelWarn, "Token check failed", slog.Any(logKeyError, err))
+		return nil, err
+	}
+
+	if res.LeewayAccepted {
+		m.Options.Logger.LogAttrs(r.Context(), slog.LevelInfo, "Token accepted within leeway", tokenAttrs(res.Token)...)
+	}
+
+	if res.Expired {
+		m.Options.Logger.LogAttrs(r.Context(), slog.LevelInfo, "Token accepted within expiry grace", tokenAttrs(res.Token)...)
+	}
+
+	for _, dryErr := range res.DryRunErrors {
+		m.Options.Logger.LogAttrs(r.Context(), slog.LevelInfo, "Token failed a dry-run validator", slog.Any(logKeyError, dryErr))
+	}
+
+	if m.Options.AuditFunc != nil {
+		m.Options.AuditFunc(m.auditEvent(r, res))
+	}
+
+	m.Options.Logger.LogAttrs(r.Context(), slog.LevelDebug, "Token check succeeded", tokenAttrs(res.Token)...)
+	return res, nil
+}
+
+func (m *Core) run(r *http.Request, claims jwt.Claims, extra ...Validator) (*Result, error) {
+	// Check transport
+	if m.Options.RequireTLS && !m.secure(r) {
+		return nil, ErrInsecureTransport
+	}
+
+	// Extract token, letting FromSources report the source. The request is
+	// only copied for it if the Extractor is from FromSources
+	var source string
+	var name *string
+	start := m.clock()
+	extractFrom := r
+	if m.sources {
+		name = new(string)
+		extractFrom = r.WithContext(context.WithValue(r.Context(), sourceKey{}, name))
+	}
+
+	raw, err := m.rawToken(extractFrom)
+	if err != nil {
+		return nil, err
+	}
+
+	if name != nil {
+		source = *name
+	}
+
+	m.Options.Logger.LogAttrs(r.Context(), slog.LevelDebug, "Token extracted", slog.String(logKeySource, source))
+
+	// Attach detached payload
+	if raw, err = m.withPayload(r, raw); err != nil {
+		return nil, err
+	}
+
+	extraction := elapsed(start)
+
+	res, err := m.validate(r.Context(), r, raw, claims, extra...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Detect replays last, as it marks the token used
+	start = m.clock()
+	if err = m.consume(r.Context(), res); err != nil {
+		return nil, err
+	}
+	res.Timings.Validation += elapsed(start)
+
+	// Touch session
+	if m.Options.SessionTouch != nil {
+		if err = m.touchSession(r.Context(), res); err != nil {
+			return nil, err
+		}
+	}
+
+	res.Source = source
+	res.Timings.Extraction = extraction
+	return res, nil
+}
+
+// validate parses the raw token and validates it, running the extra
+// validators after Validators. Unlike run, it doesn't need the request,
+// which is only passed to the validators and AssociatedData, and may be nil.
+func (m *Core) validate(ctx context.Context, r *http.Request, raw string, claims jwt.Claims, extra ...Validator) (*Result, error) {
+	// Decrypt nested token
+	if m.Options.NestedDecrypt != nil && strings.Count(raw, ".") == 4 {
+		if err := contextError(ctx); err != nil {
+			return nil, err
+		}
+
+		inner, err := m.Options.NestedDecrypt(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrDecryptionFailed, err)
+		}
+
+		if strings.Count(inner, ".") != 2 {
+			return nil, fmt.Errorf("%w: inner token is not signed", ErrDecryptionFailed)
+		}
+
+		raw = inner
+	}
+
+	// Check segments
+	if max := m.Options.MaxClaimsBytes; max > 0 {
+		if err := checkSegments(raw, max); err != nil {
+			return nil, err
+		}
+	}
+
 	// Parse token
-	token, err := jwt.Parse(raw, m.Options.Keyfunc)
+	res := &Result{Verified: true}
+	data, err := m.associatedData(r)
+	if err != nil {
+		return nil, err
+	}
+
+	start := m.clock()
+	var token *jwt.Token
+	if m.trustedHop(r) {
+		res.Verified = false
+		res.TrustedHop = true
+		token, err = m.parseUnverified(raw, claims)
+	} else if data == nil && m.Options.ValidationCache.verified(m.id, raw) {
+		token, err = m.parseCached(raw, claims)
+	} else {
+		token, err = m.parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+			bindAssociatedData(token, data)
+			keyStart := m.clock()
+			key, err := m.selectKey(ctx, token)
+			res.Timings.KeySelection += elapsed(keyStart)
+			return key, err
+		})
+		if previous := m.previousHMACSecret(); previous != nil && isSignatureInvalid(err) {
+			token, err = m.parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+				bindAssociatedData(token, data)
+				return previous, nil
+			})
+		}
+		if m.Options.FallbackKeyfunc != nil && isSignatureInvalid(err) {
+			fallback, fallbackErr := m.parser.ParseWithClaims(raw, claims, func(token *jwt.Token) (interface{}, error) {
+				bindAssociatedData(token, data)
+				return m.fallbackKey(token)
+			})
+			if isSignatureVerified(fallbackErr) {
+				token, err = fallback, fallbackErr
+			}
+		}
+		unbindAssociatedData(token)
+
+		if err == nil && data == nil {
+			m.Options.ValidationCache.add(m.id, raw, token)
+		}
+	}
+	res.Timings.Verification = elapsed(start) - res.Timings.KeySelection
+
+	// Introspect opaque token
+	if err != nil && m.Options.Introspector != nil && isMalformed(err) {
+		if token, err = m.introspect(ctx, raw); err != nil {
+			return nil, err
+		}
+
+		res.Introspected = true
+	}
+
+	if m.isUnencoded(token, raw) {
+		return nil, ErrUnsupportedB64False
+	}
+
+	if token != nil && !m.knowsCritical(token.Header) {
+		return nil, ErrUnsupportedCritical
+	}
+
+	if err != nil && m.Options.DegradedMode && isKeyUnavailable(err) {
+		res.Verified = false
+		res.VerifyError = err
+		token, err = m.parseUnverified(raw, claims)
+	}
+
+	if err != nil && m.acceptTimeError(token, err, res) {
+		token.Valid = res.Verified
+		err = nil
+	}
+
 	if err != nil {
-		return nil, fmt.Errorf("Error parsing token: %v", err)
+		return nil, parseError(err)
+	}
+
+	start = m.clock()
+
+	// Descend into nested claims
+	if m.Options.ClaimsRoot != "" && !res.Introspected {
+		if err = m.descendClaims(token, claims, res); err != nil {
+			return nil, err
+		}
 	}
 
 	// Check if token is valid
-	if err = m.validateToken(token); err != nil {
+	if res.Introspected {
+		err = m.validateClaims(token, m.audience(r))
+	} else {
+		err = m.validateToken(token, m.audience(r))
+	}
+	if err != nil {
 		return nil, err
 	}
 
-	return token, nil
+	// Normalize claims
+	if claims, ok := token.Claims.(jwt.MapClaims); ok && m.Options.ClaimTransform != nil {
+		token.Claims = m.Options.ClaimTransform(claims)
+	}
+
+	// Run custom validators
+	for _, v := range m.Options.Validators {
+		if err = m.runValidator(v, token, r); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, v := range extra {
+		if err = m.runValidator(v, token, r); err != nil {
+			return nil, err
+		}
+	}
+
+	for _, v := range m.Options.DryRunValidators {
+		if dryErr := m.runValidator(v, token, r); dryErr != nil {
+			res.DryRunErrors = append(res.DryRunErrors, dryErr)
+		}
+	}
+
+	// Apply the deny policy
+	if m.Options.Deny != nil {
+		claims, err := claimsMap(token)
+		if err != nil {
+			return nil, err
+		}
+
+		if err = m.Options.Deny(claims, r); err != nil {
+			return nil, Forbidden(err)
+		}
+	}
+
+	res.Timings.Validation = elapsed(start)
+	res.Token = token
+	return res, nil
 }
 
-// GetWithClaims extracts and validates the JWT token from the request,
-// as well as the supplied claims. It returns the parsed token with the
-// supplied claims, if successful.
-func (m *Core) GetWithClaims(r *http.Request, claims jwt.Claims) (*jwt.Token, error) {
-	// Extract token
-	raw, err := m.rawToken(r)
+// audience returns the audience required for the request, which may be nil.
+func (m *Core) audience(r *http.Request) string {
+	aud, longest := m.Options.Audience, -1
+	if r == nil {
+		return aud
+	}
+
+	for prefix, a := range m.Options.AudienceByPrefix {
+		if len(prefix) > longest && strings.HasPrefix(r.URL.Path, prefix) {
+			aud, longest = a, len(prefix)
+		}
+	}
+
+	return aud
+}
+
+// withPayload attaches the detached payload to the raw token, if the request
+// carries one.
+func (m *Core) withPayload(r *http.Request, raw string) (string, error) {
+	if m.Options.DetachedPayload == nil && r.Context().Value(webhookBodyKey{}) == nil {
+		return raw, nil
+	}
+
+	return m.attachPayload(r, raw)
+}
+
+// consume marks the validated token as used in ReplayStore, if it's set.
+func (m *Core) consume(ctx context.Context, res *Result) error {
+	if m.Options.ReplayStore == nil {
+		return nil
+	}
+
+	if err := contextError(ctx); err != nil {
+		return err
+	}
+
+	return m.checkReplay(res.Token)
+}
+
+// checkReplay marks the token's 'jti' as consumed, failing if it already was.
+func (m *Core) checkReplay(token *jwt.Token) error {
+	claims, err := claimsMap(token)
+	if err != nil {
+		return err
+	}
+
+	jti, _ := claims["jti"].(string)
+	if jti == "" {
+		return ErrTokenReplay
+	}
+
+	// Remember the jti for as long as the token is still accepted
+	exp, ok := expiresAt(token)
+	if ok {
+		exp = exp.Add(m.Options.Leeway + m.Options.ExpiredGrace)
+	}
+
+	seen, err := m.Options.ReplayStore.CheckAndMark(jti, exp)
+	if err != nil {
+		return fmt.Errorf("Error checking replay: %w", err)
+	}
+
+	if seen {
+		return ErrTokenReplay
+	}
+
+	return nil
+}
+
+// trustedHop reports whether the request carries TrustedSkipHeader with the
+// TrustedSkipValue.
+func (m *Core) trustedHop(r *http.Request) bool {
+	header, value := m.Options.TrustedSkipHeader, m.Options.TrustedSkipValue
+	if r == nil || header == "" || value == "" {
+		return false
+	}
+
+	return subtle.ConstantTimeCompare([]byte(r.Header.Get(header)), []byte(value)) == 1
+}
+
+func (m *Core) secure(r *http.Request) bool {
+	if r.TLS != nil {
+		return true
+	}
+
+	return m.Options.TrustForwardedProto && strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https")
+}
+
+func (m *Core) selectKey(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	m.mu.RLock()
+	revoked := m.revoked[kid]
+	secret := m.hmac.current
+	m.mu.RUnlock()
+	if revoked {
+		return nil, ErrRevokedKID
+	}
+
+	if secret != nil {
+		return secret, nil
+	}
+
+	if m.Options.KeyResolver == nil && m.Options.Keyfunc == nil {
+		return nil, errors.New("no Keyfunc was provided")
+	}
+
+	if err := contextError(ctx); err != nil {
+		return nil, err
+	}
+
+	m.Options.Logger.LogAttrs(ctx, slog.LevelDebug, "Selecting key", tokenAttrs(token)...)
+	var key interface{}
+	var err error
+	if m.Options.KeyResolver != nil {
+		key, err = m.Options.KeyResolver.Resolve(ctx, kid, token.Method.Alg())
+	} else {
+		key, err = m.Options.Keyfunc(token)
+	}
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse token
-	token, err := jwt.ParseWithClaims(raw, claims, m.Options.Keyfunc)
+	if err := m.checkKeySize(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// fallbackKey selects the key of Options.FallbackKeyfunc.
+func (m *Core) fallbackKey(token *jwt.Token) (interface{}, error) {
+	key, err := m.Options.FallbackKeyfunc(token)
 	if err != nil {
-		return nil, fmt.Errorf("Error parsing token: %v", err)
+		return nil, err
 	}
 
-	// Get if token is valid
-	if err = m.validateToken(token); err != nil {
+	if err := m.checkKeySize(key); err != nil {
 		return nil, err
 	}
 
-	return token, nil
+	return key, nil
 }
 
-// Helper functions
-// ---
+func (m *Core) extract(r *http.Request) (string, error) {
+	if e, ok := r.Context().Value(extractorKey{}).(TokenExtractor); ok {
+		return e(r)
+	}
+
+	if m.Options.ConfigExtractor != nil {
+		return m.Options.ConfigExtractor(r, m.Options)
+	}
+
+	return m.Options.Extractor(r)
+}
 
 func (m *Core) rawToken(r *http.Request) (string, error) {
 	// Extract token
-	raw, err := m.Options.Extractor(r)
+	raw, err := m.extract(r)
+	if errors.Is(err, ErrNoCredentials) || (m.Options.StrictEmptyToken && errors.Is(err, ErrEmptyBearerToken)) {
+		return "", err
+	}
+
 	if err != nil {
-		return "", fmt.Errorf("Error extracting token: %v", err)
+		return "", fmt.Errorf("Error extracting token: %w", err)
 	}
 
 	// Check if token is present
 	if raw == "" {
-		return "", errors.New("Token not found")
+		return "", errTokenNotFound
+	}
+
+	// Strip prefix
+	if prefix := m.Options.TokenPrefix; prefix != "" {
+		if !strings.HasPrefix(raw, prefix) {
+			return "", ErrBadPrefix
+		}
+
+		raw = raw[len(prefix):]
 	}
 
 	return raw, nil
 }
 
-func (m *Core) validateToken(token *jwt.Token) error {
-	// Verify hashing algorithm
-	if alg := m.Options.SigningMethod.Alg(); alg != token.Header["alg"] {
-		return fmt.Errorf("Invalid token algorithm. Wanted %s, got %s", alg, token.Header["alg"])
+func (m *Core) validateToken(token *jwt.Token, aud string) error {
+	// Verify hashing algorithm. The parser derives Method from the 'alg'
+	// header, so comparing it spares a map lookup. ValidMethods take
+	// precedence, and are checked again for tokens the parser didn't verify
+	if len(m.Options.ValidMethods) > 0 {
+		if !containsString(m.Options.ValidMethods, token.Method.Alg()) {
+			return fmt.Errorf("Invalid token algorithm. Wanted one of %v, got %s", m.Options.ValidMethods, token.Method.Alg())
+		}
+	} else if alg := m.Options.SigningMethod.Alg(); alg != token.Method.Alg() {
+		return fmt.Errorf("Invalid token algorithm. Wanted %s, got %s", alg, token.Method.Alg())
+	}
+
+	// Verify type
+	if len(m.Options.AllowedTypes) > 0 || m.Options.RequireTyp {
+		if err := m.checkType(token); err != nil {
+			return err
+		}
+	}
+
+	return m.validateClaims(token, aud)
+}
+
+// validateClaims validates the token's claims, but not its algorithm, which
+// introspected tokens don't have.
+func (m *Core) validateClaims(token *jwt.Token, aud string) error {
+	claims, err := claimsMap(token)
+	if err != nil {
+		return err
+	}
+
+	// Verify audience
+	if forbidden := m.Options.ForbiddenAudiences; len(forbidden) > 0 {
+		if err = checkForbiddenAudiences(claims, forbidden, m.Options.NormalizeURLClaims); err != nil {
+			return err
+		}
+	}
+
+	if aud != "" {
+		if err = checkAudience(claims, aud, m.Options.NormalizeURLClaims); err != nil {
+			return err
+		}
+	}
+
+	// Verify issuer
+	if iss := m.Options.Issuer; iss != "" {
+		if err = checkIssuer(claims, iss, m.Options.NormalizeURLClaims); err != nil {
+			return err
+		}
+	}
+
+	// Verify token use
+	if use := m.Options.ExpectedTokenUse; use != "" {
+		if got, _ := claims[m.Options.TokenUseClaim].(string); got != use {
+			return fmt.Errorf("%w: got '%s', want '%s'", ErrWrongTokenUse, got, use)
+		}
+	}
+
+	// Verify audience of a federated issuer
+	if m.issuers != nil {
+		if err = m.checkFederatedAudience(claims); err != nil {
+			return err
+		}
+	}
+
+	// Verify expiration presence
+	if m.Options.TreatNoExpAsExpired {
+		if err = checkExpPresent(claims); err != nil {
+			return err
+		}
+	}
+
+	// Verify age
+	if maxAge := m.Options.MaxAge; maxAge > 0 {
+		if err = checkMaxAge(claims, maxAge); err != nil {
+			return err
+		}
+	}
+
+	// Verify authentication methods
+	if len(m.Options.DisallowedAMR) > 0 {
+		if err = checkAMR(claims, m.Options.DisallowedAMR); err != nil {
+			return err
+		}
+	}
+
+	// Verify claims
+	names := make([]string, 0, len(m.Options.ClaimValidators))
+	for name := range m.Options.ClaimValidators {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		if err = checkClaim(claims, name, m.Options.ClaimValidators[name]); err != nil {
+			return err
+		}
 	}
 
 	return nil
 }
+
+// attachPayload puts the detached payload, or the body of VerifyWebhook, into
+// the token's empty payload segment. Tokens with a payload are returned as
+// they are, unless they must sign the body.
+func (m *Core) attachPayload(r *http.Request, raw string) (string, error) {
+	body, webhook := r.Context().Value(webhookBodyKey{}).([]byte)
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 || parts[1] != "" {
+		if webhook {
+			return "", ErrNotDetached
+		}
+
+		return raw, nil
+	}
+
+	header, err := m.decodeHeader(raw)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing token: %w", err)
+	}
+
+	if unencoded(header) {
+		return "", ErrUnsupportedB64False
+	}
+
+	payload := body
+	if !webhook {
+		if payload, err = m.Options.DetachedPayload(r); err != nil {
+			return "", fmt.Errorf("Error reading detached payload: %w", err)
+		}
+	}
+
+	return parts[0] + "." + jwt.EncodeSegment(payload) + "." + parts[2], nil
+}
+
+// knowsCritical reports whether all the extensions in the 'crit' header are
+// known. A 'crit' header that isn't a non-empty list of names is invalid.
+func (m *Core) knowsCritical(header map[string]interface{}) bool {
+	value, ok := header["crit"]
+	if !ok {
+		return true
+	}
+
+	crit, ok := value.([]interface{})
+	if !ok || len(crit) == 0 {
+		return false
+	}
+
+	for _, v := range crit {
+		name, _ := v.(string)
+		if name != "b64" && !containsString(m.Options.KnownCritical, name) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// isUnencoded reports whether the token declares an unencoded payload with
+// the "b64": false header (RFC 7797). The raw token's header is only decoded
+// when the parser couldn't.
+func (m *Core) isUnencoded(token *jwt.Token, raw string) bool {
+	if token != nil && token.Header != nil {
+		return unencoded(token.Header)
+	}
+
+	header, err := m.decodeHeader(raw)
+	return err == nil && unencoded(header)
+}
+
+func unencoded(header map[string]interface{}) bool {
+	b64, ok := header["b64"].(bool)
+	return ok && !b64
+}
+
+// decodeHeader decodes the raw token's header segment.
+func (m *Core) decodeHeader(raw string) (map[string]interface{}, error) {
+	segment := raw
+	if i := strings.IndexByte(raw, '.'); i >= 0 {
+		segment = raw[:i]
+	}
+
+	data, err := jwt.DecodeSegment(segment)
+	if err != nil {
+		return nil, err
+	}
+
+	var header map[string]interface{}
+	if err = m.Options.JSONUnmarshal(data, &header); err != nil {
+		return nil, err
+	}
+
+	return header, nil
+}
+
+// checkSegments bounds the decoded size of the token's header and claims
+// segments, then checks each one that decodes to a JSON object is nothing
+// but that object. Other malformed segments are left to the parser.
+func checkSegments(raw string, max int) error {
+	parts := strings.SplitN(raw, ".", 3)
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+
+	for _, part := range parts {
+		if base64.RawURLEncoding.DecodedLen(len(strings.TrimRight(part, "="))) > max {
+			return ErrClaimsTooLarge
+		}
+	}
+
+	for _, part := range parts {
+		data, err := jwt.DecodeSegment(part)
+		if err != nil || !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("{")) {
+			continue
+		}
+
+		if !json.Valid(data) {
+			return parseError(jwt.NewValidationError("token segment has data after its JSON", jwt.ValidationErrorMalformed))
+		}
+	}
+
+	return nil
+}
+
+// descendClaims replaces the token's claims with the object nested under
+// ClaimsRoot, and validates them.
+func (m *Core) descendClaims(token *jwt.Token, claims jwt.Claims, res *Result) error {
+	payload, err := m.decodeClaims(token.Raw)
+	if err != nil {
+		return err
+	}
+
+	nested, ok := payload[m.Options.ClaimsRoot].(map[string]interface{})
+	if !ok {
+		return ErrClaimsRootMissing
+	}
+
+	if _, ok := claims.(jwt.MapClaims); ok {
+		token.Claims = jwt.MapClaims(nested)
+	} else {
+		data, err := json.Marshal(nested)
+		if err != nil {
+			return fmt.Errorf("Error reading claims: %w", err)
+		}
+
+		if err = m.Options.JSONUnmarshal(data, claims); err != nil {
+			return fmt.Errorf("Error reading claims: %w", err)
+		}
+
+		token.Claims = claims
+	}
+
+	if err = token.Claims.Valid(); err != nil && !m.acceptTimeError(token, err, res) {
+		return parseError(err)
+	}
+
+	return nil
+}
+
+// decodeClaims decodes the token's claims segment, without verifying it.
+func (m *Core) decodeClaims(raw string) (map[string]interface{}, error) {
+	parts := strings.Split(raw, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("Token contains an invalid number of segments")
+	}
+
+	data, err := jwt.DecodeSegment(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding claims: %w", err)
+	}
+
+	var claims map[string]interface{}
+	if err = m.Options.JSONUnmarshal(data, &claims); err != nil {
+		return nil, fmt.Errorf("Error decoding claims: %w", err)
+	}
+
+	return claims, nil
+}
+
+// parseUnverified parses the token without verifying its signature. The
+// claims are still validated.
+func (m *Core) parseUnverified(raw string, claims jwt.Claims) (*jwt.Token, error) {
+	token, _, err := m.parser.ParseUnverified(raw, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = token.Claims.Valid(); err != nil {
+		return token, err
+	}
+
+	return token, nil
+}
+
+// timeErrors are the jwt-go validation errors Leeway can excuse.
+const timeErrors = jwt.ValidationErrorExpired | jwt.ValidationErrorNotValidYet | jwt.ValidationErrorIssuedAt
+
+// acceptTimeError reports whether the parsing error is only due to the
+// token's time claims, and is accepted thanks to Leeway or ExpiredGrace,
+// flagging the result accordingly.
+func (m *Core) acceptTimeError(token *jwt.Token, err error, res *Result) bool {
+	if m.withinLeeway(token, err) {
+		res.LeewayAccepted = true
+		return true
+	}
+
+	if m.withinGrace(token, err) {
+		res.Expired = true
+		return true
+	}
+
+	return false
+}
+
+// withinGrace reports whether parsing failed only due to the token being
+// expired, for no longer than ExpiredGrace.
+func (m *Core) withinGrace(token *jwt.Token, err error) bool {
+	ve, ok := err.(*jwt.ValidationError)
+	if m.Options.ExpiredGrace <= 0 || token == nil || !ok || ve.Errors != jwt.ValidationErrorExpired {
+		return false
+	}
+
+	exp, ok := expiresAt(token)
+	return ok && time.Since(exp) <= m.Options.ExpiredGrace
+}
+
+// withinLeeway reports whether parsing failed only due to the token's time
+// claims, and they pass when Leeway is taken into account.
+func (m *Core) withinLeeway(token *jwt.Token, err error) bool {
+	ve, ok := err.(*jwt.ValidationError)
+	if m.Options.Leeway <= 0 || token == nil || !ok || ve.Errors&^timeErrors != 0 {
+		return false
+	}
+
+	claims, err := claimsMap(token)
+	if err != nil {
+		return false
+	}
+
+	// Compare times, so sub-second leeway isn't truncated
+	now, leeway := time.Now(), m.Options.Leeway
+	if exp, ok := numericClaim(claims, "exp"); ok && now.After(time.Unix(exp, 0).Add(leeway)) {
+		return false
+	}
+
+	if nbf, ok := numericClaim(claims, "nbf"); ok && now.Add(leeway).Before(time.Unix(nbf, 0)) {
+		return false
+	}
+
+	if iat, ok := numericClaim(claims, "iat"); ok && now.Add(leeway).Before(time.Unix(iat, 0)) {
+		return false
+	}
+
+	return true
+}
+
+// contextError returns the context's error wrapped as ErrContextCancelled,
+// so steps that may touch the network are skipped for abandoned requests.
+func contextError(ctx context.Context) error {
+	if err := ctx.Err(); err != nil {
+		return fmt.Errorf("%w: %w", ErrContextCancelled, err)
+	}
+
+	return nil
+}
+
+// clock returns the current time if Options.Timings is on, sparing the clock
+// read otherwise.
+func (m *Core) clock() time.Time {
+	if !m.Options.Timings {
+		return time.Time{}
+	}
+
+	return time.Now()
+}
+
+// elapsed returns the time since start, read by clock.
+func elapsed(start time.Time) time.Duration {
+	if start.IsZero() {
+		return 0
+	}
+
+	return time.Since(start)
+}
+
+// isSignatureInvalid reports whether parsing failed due to the signature.
+func isSignatureInvalid(err error) bool {
+	ve, ok := err.(*jwt.ValidationError)
+	return ok && ve.Errors&jwt.ValidationErrorSignatureInvalid != 0
+}
+
+// isSignatureVerified reports whether parsing verified the signature, even
+// if the claims are invalid, e.g. expired.
+func isSignatureVerified(err error) bool {
+	ve, ok := err.(*jwt.ValidationError)
+	return err == nil || ok && ve.Errors&(jwt.ValidationErrorSignatureInvalid|jwt.ValidationErrorUnverifiable|jwt.ValidationErrorMalformed) == 0
+}
+
+// isMalformed reports whether parsing failed because the token isn't a JWT.
+func isMalformed(err error) bool {
+	ve, ok := err.(*jwt.ValidationError)
+	return ok && ve.Errors&jwt.ValidationErrorMalformed != 0
+}
+
+// isKeyUnavailable reports whether parsing failed only because the Keyfunc
+// returned ErrKeyUnavailable, possibly wrapped.
+func isKeyUnavailable(err error) bool {
+	ve, ok := err.(*jwt.ValidationError)
+	return ok && ve.Errors == jwt.ValidationErrorUnverifiable && errors.Is(ve.Inner, ErrKeyUnavailable)
+}
+
+// parseError converts an error from jwt-go into the one the checking
+// functions return.
+func parseError(err error) error {
+	if ve, ok := err.(*jwt.ValidationError); ok {
+		if ve.Errors == jwt.ValidationErrorExpired {
+			return fmt.Errorf("Error parsing token: %w", ErrTokenExpired)
+		}
+
+		for _, sentinel := range innerErrors {
+			if errors.Is(ve.Inner, sentinel) {
+				return ve.Inner
+			}
+		}
+	}
+
+	return fmt.Errorf("Error parsing token: %w", err)
+}
+
+// innerErrors are the errors of selecting keys and verifying signatures that
+// parseError returns as they are.
+var innerErrors = []error{
+	ErrRevokedKID,
+	ErrUnknownKID,
+	ErrInvalidIssuer,
+	ErrUntrustedIssuer,
+	ErrContextCancelled,
+	ErrAssociatedDataMismatch,
+	ErrWeakKey,
+	ErrAmbiguousKey,
+	ErrAlgMismatchForKID,
+}
+
+// claimsMap returns the token's claims as jwt.MapClaims. Claims of a custom
+// type are converted by a round trip through JSON.
+func claimsMap(token *jwt.Token) (jwt.MapClaims, error) {
+	if claims, ok := token.Claims.(jwt.MapClaims); ok {
+		return claims, nil
+	}
+
+	data, err := json.Marshal(token.Claims)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading claims: %w", err)
+	}
+
+	claims := jwt.MapClaims{}
+	if err = json.Unmarshal(data, &claims); err != nil {
+		return nil, fmt.Errorf("Error reading claims: %w", err)
+	}
+
+	return claims, nil
+}
+
+// expiresAt returns the time in the token's 'exp' claim, if it has one.
+func expiresAt(token *jwt.Token) (time.Time, bool) {
+	claims, err := claimsMap(token)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	exp, ok := numericClaim(claims, "exp")
+	if !ok {
+		return time.Time{}, false
+	}
+
+	return time.Unix(exp, 0), true
+}
+
+// checkType checks the token's 'typ' header against Options.AllowedTypes
+// and Options.RequireTyp.
+func (m *Core) checkType(token *jwt.Token) error {
+	typ, ok := token.Header["typ"].(string)
+	if !ok || typ == "" {
+		if m.Options.RequireTyp {
+			return fmt.Errorf("%w: no 'typ' header", ErrInvalidType)
+		}
+
+		return nil
+	}
+
+	if len(m.Options.AllowedTypes) == 0 {
+		return nil
+	}
+
+	for _, allowed := range m.Options.AllowedTypes {
+		if strings.EqualFold(mediaType(allowed), mediaType(typ)) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: '%s'", ErrInvalidType, typ)
+}
+
+// mediaType strips the optional "application/" prefix of the media type.
+func mediaType(typ string) string {
+	if len(typ) > len("application/") && strings.EqualFold(typ[:len("application/")], "application/") {
+		return typ[len("application/"):]
+	}
+
+	return typ
+}
+
+// numericClaim returns the named claim as an int64, if it is a non-zero number.
+func numericClaim(claims jwt.MapClaims, name string) (int64, bool) {
+	var value int64
+	switch v := claims[name].(type) {
+	case float64:
+		value = int64(v)
+	case json.Number:
+		var err error
+		if value, err = v.Int64(); err != nil {
+			f, _ := v.Float64()
+			value = int64(f)
+		}
+	}
+
+	return value, value != 0
+}
+
+// audiences returns the token's 'aud' claim, which is either a string or
+// an array of strings.
+func audiences(claims jwt.MapClaims) []string {
+	switch aud := claims["aud"].(type) {
+	case string:
+		return []string{aud}
+	case []interface{}:
+		res := make([]string, 0, len(aud))
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				res = append(res, s)
+			}
+		}
+
+		return res
+	case []string:
+		return aud
+	}
+
+	return nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
@@ -1,7 +1,15 @@
 package jaywt_test
 
 import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/json"
 	"errors"
+	"fmt"
 	"github.com/oreqizer/go-jaywt"
 	"gopkg.in/dgrijalva/jwt-go.v3"
 	"net/http"
@@ -14,6 +22,8 @@ import (
 const sampleSecret = "wowSecurity9001"
 const sampleSubject = "auth0|asdfomfg12345678"
 
+var sampleRSAKey = mustGenerateRSAKey()
+
 func TestNewDefault(t *testing.T) {
 	j := jaywt.New(&jaywt.Options{})
 
@@ -30,6 +40,10 @@ func TestNewDefault(t *testing.T) {
 	if j.Options.Keyfunc != nil {
 		t.Error("Keyfunc must default to 'nil'")
 	}
+
+	if j.Options.ScopeSeparator != " " {
+		t.Errorf("ScopeSeparator == %q, want %q", j.Options.ScopeSeparator, " ")
+	}
 }
 
 const customKey = "IAmACustomKeyLol"
@@ -64,18 +78,27 @@ func TestNewCustom(t *testing.T) {
 const headerTokenOk = "asdf1234.asdfasdf12341234.adsf1234"
 const headerOk = "Bearer " + headerTokenOk
 
+var headerTable = []string{
+	headerOk,
+	"bearer " + headerTokenOk,
+	"Bearer   " + headerTokenOk,
+	"Bearer " + headerTokenOk + "  ",
+}
+
 func TestFromAuthHeaderOk(t *testing.T) {
-	req := httptest.NewRequest(http.MethodGet, "/", nil)
-	req.Header.Set("Authorization", headerOk)
+	for _, header := range headerTable {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", header)
 
-	token, err := jaywt.FromAuthHeader(req)
-	if err != nil {
-		t.Error(err)
-		return
-	}
+		token, err := jaywt.FromAuthHeader(req)
+		if err != nil {
+			t.Errorf("%q: %v", header, err)
+			continue
+		}
 
-	if token != headerTokenOk {
-		t.Errorf("Token: %s, want %s", token, headerTokenOk)
+		if token != headerTokenOk {
+			t.Errorf("%q: Token: %s, want %s", header, token, headerTokenOk)
+		}
 	}
 }
 
@@ -84,6 +107,9 @@ var headerTableBad = []string{
 	"Berer typoHere",
 	"Beerer lolWtfNoAlcohol",
 	"theIntroIsMissing",
+	"Bearer ",
+	"Bearer    ",
+	"Bearer",
 }
 
 func TestFromAuthHeaderBad(t *testing.T) {
@@ -98,6 +124,39 @@ func TestFromAuthHeaderBad(t *testing.T) {
 	}
 }
 
+func TestFromAuthHeaderBlankToken(t *testing.T) {
+	for _, header := range []string{"Bearer", "bearer ", "Bearer    "} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", header)
+
+		if _, err := jaywt.FromAuthHeader(req); err != jaywt.ErrEmptyBearerToken {
+			t.Errorf("%q: Got %v, want %v", header, err, jaywt.ErrEmptyBearerToken)
+		}
+	}
+}
+
+func TestGetStrictEmptyToken(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	if _, err := p.Get(req); err == nil || err == jaywt.ErrEmptyBearerToken {
+		t.Errorf("Got %v, want an extraction error", err)
+	}
+
+	p.Options.StrictEmptyToken = true
+	if _, err := p.Get(req); err != jaywt.ErrEmptyBearerToken {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrEmptyBearerToken)
+	}
+
+	// Requests without the header still lack a token
+	if _, err := p.Get(httptest.NewRequest(http.MethodGet, "/", nil)); err == nil || err == jaywt.ErrEmptyBearerToken {
+		t.Errorf("Got %v, want a missing token", err)
+	}
+}
+
 func TestFromAuthHeaderEmpty(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 
@@ -229,6 +288,22 @@ func TestGetBadSigning(t *testing.T) {
 	}
 }
 
+func TestGetValidMethodsPrecedence(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:       sampleKeyfunc,
+		SigningMethod: jwt.SigningMethodHS256,
+		ValidMethods:  []string{"HS256", "HS384"},
+	})
+
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS384, jwt.MapClaims{"sub": sampleSubject})); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS512, jwt.MapClaims{"sub": sampleSubject})); err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
 func TestGetWithClaimsOk(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	raw := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.StandardClaims{
@@ -294,6 +369,20 @@ func TestGetWithClaimsBadExtraction(t *testing.T) {
 	}
 }
 
+func TestGetExtractorErrorChain(t *testing.T) {
+	errTampered := errors.New("Cookie was tampered with")
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Extractor: func(_ *http.Request) (string, error) {
+			return "", fmt.Errorf("Error reading cookie: %w", errTampered)
+		},
+	})
+
+	if _, err := p.Get(httptest.NewRequest(http.MethodGet, "/", nil)); !errors.Is(err, errTampered) {
+		t.Errorf("Got %v, want it to wrap %v", err, errTampered)
+	}
+}
+
 func TestGetWithClaimsNoToken(t *testing.T) {
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	p := jaywt.New(&jaywt.Options{
@@ -398,13 +487,1461 @@ func TestGetWithClaimsInvalid(t *testing.T) {
 	}
 }
 
-// Helper functions
-// ---
+func TestGetExpired(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(-1 * time.Hour).Unix(),
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
 
-func sampleKeyfunc(_ *jwt.Token) (interface{}, error) {
-	return []byte(sampleSecret), nil
+	_, err := p.Get(req)
+	if !errors.Is(err, jaywt.ErrTokenExpired) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrTokenExpired)
+	}
 }
 
-func badKeyfunc(_ *jwt.Token) (interface{}, error) {
-	return nil, errors.New("Keyfunc error")
+func TestGetTreatNoExpAsExpired(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:             sampleKeyfunc,
+		TreatNoExpAsExpired: true,
+	})
+
+	_, err := p.Get(req)
+	if !errors.Is(err, jaywt.ErrTokenExpired) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrTokenExpired)
+	}
+}
+
+func TestGetWithClaimsTreatNoExpAsExpiredOk(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.StandardClaims{
+		Subject:   sampleSubject,
+		ExpiresAt: time.Now().Add(1 * time.Hour).Unix(),
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:             sampleKeyfunc,
+		TreatNoExpAsExpired: true,
+	})
+
+	_, err := p.GetWithClaims(req, &jwt.StandardClaims{})
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetResultOk(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	res, err := p.GetResult(req, jwt.MapClaims{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if !res.Verified {
+		t.Error("Result should be verified")
+	}
+}
+
+func TestGetResultDegraded(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:      unavailableKeyfunc,
+		DegradedMode: true,
+	})
+
+	res, err := p.GetResult(req, jwt.MapClaims{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res.Verified {
+		t.Error("Result should not be verified")
+	}
+
+	if res.VerifyError == nil {
+		t.Error("VerifyError should be set")
+	}
+
+	if sub := res.Token.Claims.(jwt.MapClaims)["sub"]; sub != sampleSubject {
+		t.Errorf("Claims subject is %s, want %s", sub, sampleSubject)
+	}
+
+	// Get should never return an unverified token
+	if _, err = p.Get(req); err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestGetResultDegradedWrapped(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: func(_ *jwt.Token) (interface{}, error) {
+			return nil, fmt.Errorf("vault: %w", jaywt.ErrKeyUnavailable)
+		},
+		DegradedMode: true,
+	})
+
+	res, err := p.GetResult(req, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Verified {
+		t.Error("Result should not be verified")
+	}
+}
+
+func TestGetResultDegradedOtherError(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:      badKeyfunc,
+		DegradedMode: true,
+	})
+
+	_, err := p.GetResult(req, jwt.MapClaims{})
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestGetTrustedSkipHeader(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:           sampleKeyfunc,
+		TrustedSkipHeader: "X-Mesh-Authenticated",
+		TrustedSkipValue:  "meshSecret",
+	})
+
+	// The signature isn't verified for requests from the trusted hop
+	req := secretRequest(t, "someOtherSecret")
+	req.Header.Set("X-Mesh-Authenticated", "meshSecret")
+	res, err := p.GetResult(req, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Verified || !res.TrustedHop {
+		t.Errorf("Got verified %t and trusted hop %t, want an unverified trusted hop", res.Verified, res.TrustedHop)
+	}
+
+	if _, err = p.Get(req); err != nil {
+		t.Error(err)
+	}
+
+	req.Header.Set("X-Mesh-Authenticated", "guessed")
+	if _, err = p.Get(req); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+
+	// Claims are still validated
+	req = sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"exp": time.Now().Add(-1 * time.Hour).Unix()})
+	req.Header.Set("X-Mesh-Authenticated", "meshSecret")
+	if _, err = p.Get(req); !errors.Is(err, jaywt.ErrTokenExpired) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrTokenExpired)
+	}
+}
+
+func TestGetResultDegradedExpired(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(-1 * time.Hour).Unix(),
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:      unavailableKeyfunc,
+		DegradedMode: true,
+	})
+
+	_, err := p.GetResult(req, jwt.MapClaims{})
+	if !errors.Is(err, jaywt.ErrTokenExpired) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrTokenExpired)
+	}
+}
+
+const sampleRolesClaim = "https://example.com/roles"
+
+func TestGetClaimTransform(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		sampleRolesClaim: []string{"admin"},
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		ClaimTransform: func(claims jwt.MapClaims) jwt.MapClaims {
+			claims["roles"] = claims[sampleRolesClaim]
+			delete(claims, sampleRolesClaim)
+			return claims
+		},
+	})
+
+	token, err := p.Get(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	claims := token.Claims.(jwt.MapClaims)
+	if _, ok := claims[sampleRolesClaim]; ok {
+		t.Errorf("Claim %s should be removed", sampleRolesClaim)
+	}
+
+	roles, ok := claims["roles"].([]interface{})
+	if !ok || len(roles) != 1 || roles[0] != "admin" {
+		t.Errorf("Claim roles is %v, want [admin]", claims["roles"])
+	}
+}
+
+func TestGetMaxClaimsBytesOk(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:        sampleKeyfunc,
+		MaxClaimsBytes: 64,
+	})
+
+	_, err := p.Get(req)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetMaxClaimsBytesTooLarge(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":  sampleSubject,
+		"junk": strings.Repeat("a", 1024),
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:        sampleKeyfunc,
+		MaxClaimsBytes: 64,
+	})
+
+	_, err := p.Get(req)
+	if err != jaywt.ErrClaimsTooLarge {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrClaimsTooLarge)
+	}
+}
+
+func TestGetMaxClaimsBytesHeader(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+	}, withHeader("junk", strings.Repeat("a", 1024)))
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:        sampleKeyfunc,
+		MaxClaimsBytes: 64,
+	})
+
+	_, err := p.Get(req)
+	if err != jaywt.ErrClaimsTooLarge {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrClaimsTooLarge)
+	}
+}
+
+func TestGetMaxClaimsBytesTrailingData(t *testing.T) {
+	header := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"sub":"` + sampleSubject + `"}{"sub":"admin"}`))
+	sig, err := jwt.SigningMethodHS256.Sign(header+"."+payload, []byte(sampleSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+header+"."+payload+"."+sig)
+
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	// jwt-go ignores the data after the claims
+	if _, err = p.Get(req); err != nil {
+		t.Fatal(err)
+	}
+
+	p.Options.MaxClaimsBytes = 1024
+	if _, err = p.Get(req); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+func TestGetAudience(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:  sampleKeyfunc,
+		Audience: "api",
+	})
+
+	for _, aud := range []interface{}{"api", []string{"web", "api"}} {
+		req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"aud": aud})
+		if _, err := p.Get(req); err != nil {
+			t.Errorf("%v: %v", aud, err)
+		}
+	}
+
+	for _, aud := range []interface{}{nil, "web", []string{"web"}} {
+		req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"aud": aud})
+		if _, err := p.Get(req); err != jaywt.ErrInvalidAudience {
+			t.Errorf("%v: Got %v, want %v", aud, err, jaywt.ErrInvalidAudience)
+		}
+	}
+}
+
+func TestGetForbiddenAudiences(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:            sampleKeyfunc,
+		Audience:           "api",
+		ForbiddenAudiences: []string{"admin"},
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"aud": []string{"web", "api"}})
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+	}
+
+	// Both checks must pass
+	for _, aud := range []interface{}{"admin", []string{"api", "admin"}} {
+		req = sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"aud": aud})
+		if _, err := p.Get(req); !errors.Is(err, jaywt.ErrForbiddenAudience) {
+			t.Errorf("%v: Got %v, want %v", aud, err, jaywt.ErrForbiddenAudience)
+		}
+	}
+}
+
+func TestGetExpectedTokenUse(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:          sampleKeyfunc,
+		ExpectedTokenUse: "access",
+	})
+
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"token_use": "access"})); err != nil {
+		t.Error(err)
+	}
+
+	for _, claims := range []jwt.MapClaims{{"token_use": "refresh"}, {"sub": sampleSubject}} {
+		if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, claims)); !errors.Is(err, jaywt.ErrWrongTokenUse) {
+			t.Errorf("%v: Got %v, want %v", claims, err, jaywt.ErrWrongTokenUse)
+		}
+	}
+
+	p.Options.TokenUseClaim = "typ"
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"typ": "access"})); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetAllowedTypes(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:      sampleKeyfunc,
+		AllowedTypes: []string{"JWT", "at+jwt"},
+	})
+
+	for _, typ := range []string{"JWT", "jwt", "at+jwt", "AT+JWT", "application/at+jwt", ""} {
+		if _, err := p.Get(typRequest(t, typ)); err != nil {
+			t.Errorf("%s: %v", typ, err)
+		}
+	}
+
+	for _, typ := range []string{"dpop+jwt", "application/", "at+jwt+x"} {
+		if _, err := p.Get(typRequest(t, typ)); !errors.Is(err, jaywt.ErrInvalidType) {
+			t.Errorf("%s: Got %v, want %v", typ, err, jaywt.ErrInvalidType)
+		}
+	}
+
+	p.Options.RequireTyp = true
+	if _, err := p.Get(typRequest(t, "")); !errors.Is(err, jaywt.ErrInvalidType) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrInvalidType)
+	}
+}
+
+func TestGetRequireTyp(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:    sampleKeyfunc,
+		RequireTyp: true,
+	})
+
+	if _, err := p.Get(typRequest(t, "anything")); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := p.Get(typRequest(t, "")); !errors.Is(err, jaywt.ErrInvalidType) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrInvalidType)
+	}
+}
+
+func TestGetDeny(t *testing.T) {
+	errSuspended := errors.New("Tenant is suspended")
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Deny: func(claims jwt.MapClaims, r *http.Request) error {
+			if r == nil {
+				t.Error("Request should be passed")
+			}
+
+			if claims["tenant"] == "suspended" {
+				return errSuspended
+			}
+
+			return nil
+		},
+	})
+
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"tenant": "active"})); err != nil {
+		t.Error(err)
+	}
+
+	_, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"tenant": "suspended"}))
+	if !errors.Is(err, errSuspended) || !jaywt.IsAuthorizationError(err) {
+		t.Errorf("Got %v, want a forbidden %v", err, errSuspended)
+	}
+}
+
+func TestGetDenyLast(t *testing.T) {
+	var validated bool
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Validators: []jaywt.Validator{
+			jaywt.ValidatorFunc(func(_ *jwt.Token, _ *http.Request) error {
+				validated = true
+				return nil
+			}),
+		},
+		Deny: func(_ jwt.MapClaims, _ *http.Request) error {
+			if !validated {
+				t.Error("Validators should run before Deny")
+			}
+
+			return nil
+		},
+	})
+
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})); err != nil {
+		t.Error(err)
+	}
+
+	p.Options.Deny = func(_ jwt.MapClaims, _ *http.Request) error {
+		t.Error("Deny should not be called for invalid tokens")
+		return nil
+	}
+
+	expired := jwt.MapClaims{"sub": sampleSubject, "exp": time.Now().Add(-time.Hour).Unix()}
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, expired)); !errors.Is(err, jaywt.ErrTokenExpired) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrTokenExpired)
+	}
+}
+
+func TestGetDenyReplayStore(t *testing.T) {
+	suspended := true
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:     sampleKeyfunc,
+		ReplayStore: jaywt.NewMemoryReplayStore(),
+		Deny: func(_ jwt.MapClaims, _ *http.Request) error {
+			if suspended {
+				return errors.New("Tenant is suspended")
+			}
+
+			return nil
+		},
+	})
+
+	// Denied tokens aren't consumed
+	claims := jwt.MapClaims{"jti": "link-1", "exp": time.Now().Add(time.Hour).Unix()}
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, claims)); !jaywt.IsAuthorizationError(err) {
+		t.Errorf("Got %v, want a forbidden error", err)
+	}
+
+	suspended = false
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, claims)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetIssuer(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Issuer:  "https://issuer.example.com",
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.StandardClaims{Issuer: "https://issuer.example.com"})
+	if _, err := p.GetWithClaims(req, &jwt.StandardClaims{}); err != nil {
+		t.Error(err)
+	}
+
+	req = sampleRequest(t, jwt.SigningMethodHS256, jwt.StandardClaims{Issuer: "https://evil.example.com"})
+	if _, err := p.GetWithClaims(req, &jwt.StandardClaims{}); err != jaywt.ErrInvalidIssuer {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrInvalidIssuer)
+	}
+}
+
+func TestGetLeeway(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Leeway:  1 * time.Minute,
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(-30 * time.Second).Unix(),
+	})
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+	}
+
+	req = sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(-2 * time.Minute).Unix(),
+	})
+	if _, err := p.Get(req); !errors.Is(err, jaywt.ErrTokenExpired) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrTokenExpired)
+	}
+}
+
+func TestGetLeewaySubSecond(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Leeway:  950 * time.Millisecond,
+	})
+
+	// Keep the next second less than 900ms away, within the leeway
+	if now := time.Now(); now.Sub(now.Truncate(time.Second)) < 100*time.Millisecond {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"nbf": time.Now().Unix() + 1,
+	})
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetResultLeewayNotBefore(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Leeway:  1 * time.Minute,
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"nbf": time.Now().Add(30 * time.Second).Unix(),
+	})
+	res, err := p.GetResult(req, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.LeewayAccepted {
+		t.Error("LeewayAccepted should be true")
+	}
+
+	req = sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"nbf": time.Now().Add(2 * time.Minute).Unix(),
+	})
+	if _, err = p.GetResult(req, jwt.MapClaims{}); err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestGetResultLeewayUnused(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Leeway:  1 * time.Minute,
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	})
+	res, err := p.GetResult(req, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.LeewayAccepted {
+		t.Error("LeewayAccepted should be false")
+	}
+}
+
+func TestGetResultExpiredGrace(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:      sampleKeyfunc,
+		ExpiredGrace: 1 * time.Hour,
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(-30 * time.Minute).Unix(),
+	})
+	res, err := p.GetResult(req, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !res.Expired || res.LeewayAccepted {
+		t.Errorf("Got Expired %t and LeewayAccepted %t, want true and false", res.Expired, res.LeewayAccepted)
+	}
+
+	req = sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(-2 * time.Hour).Unix(),
+	})
+	if _, err = p.GetResult(req, jwt.MapClaims{}); !errors.Is(err, jaywt.ErrTokenExpired) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrTokenExpired)
+	}
+}
+
+func TestGetResultExpiredGraceNotBefore(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:      sampleKeyfunc,
+		ExpiredGrace: 1 * time.Hour,
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(-30 * time.Minute).Unix(),
+		"nbf": time.Now().Add(30 * time.Minute).Unix(),
+	})
+	if _, err := p.GetResult(req, jwt.MapClaims{}); err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestOnValidate(t *testing.T) {
+	var results []*jaywt.Result
+	var errs []error
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:      sampleKeyfunc,
+		ExpiredGrace: 1 * time.Hour,
+		OnValidate: func(_ *http.Request, res *jaywt.Result, err error) {
+			results = append(results, res)
+			errs = append(errs, err)
+		},
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(-30 * time.Minute).Unix(),
+	})
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := p.Get(httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+		t.Error("Expected error, got nil")
+	}
+
+	if len(results) != 2 {
+		t.Fatalf("OnValidate calls: Got %d, want 2", len(results))
+	}
+
+	if results[0] == nil || !results[0].Expired || errs[0] != nil {
+		t.Errorf("Got %v and %v, want an expired result", results[0], errs[0])
+	}
+
+	if results[1] != nil || errs[1] == nil {
+		t.Errorf("Got %v and %v, want an error", results[1], errs[1])
+	}
+}
+
+func TestGetLeewayBadSignature(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(-30 * time.Second).Unix(),
+	}, withKey([]byte("someOtherSecret")))
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Leeway:  1 * time.Minute,
+	})
+
+	if _, err := p.Get(req); err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestGetClaimsRoot(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"data": map[string]interface{}{
+			"sub": sampleSubject,
+			"iss": "https://issuer.example.com",
+		},
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:    sampleKeyfunc,
+		Issuer:     "https://issuer.example.com",
+		ClaimsRoot: "data",
+	})
+
+	token, err := p.Get(req)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if sub := token.Claims.(jwt.MapClaims)["sub"]; sub != sampleSubject {
+		t.Errorf("Claims subject is %s, want %s", sub, sampleSubject)
+	}
+}
+
+func TestGetWithClaimsClaimsRoot(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"data": jwt.StandardClaims{Subject: sampleSubject},
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:    sampleKeyfunc,
+		ClaimsRoot: "data",
+	})
+
+	token, err := p.GetWithClaims(req, &jwt.StandardClaims{})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if sub := token.Claims.(*jwt.StandardClaims).Subject; sub != sampleSubject {
+		t.Errorf("Claims subject is %s, want %s", sub, sampleSubject)
+	}
+}
+
+func TestGetClaimsRootJSONUnmarshal(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"data": map[string]interface{}{
+			"sub":  sampleSubject,
+			"role": "admin",
+		},
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:    sampleKeyfunc,
+		ClaimsRoot: "data",
+	})
+
+	if _, err := p.GetWithClaims(req, &jwt.StandardClaims{}); err != nil {
+		t.Error(err)
+	}
+
+	p.Options.JSONUnmarshal = func(data []byte, v interface{}) error {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		return dec.Decode(v)
+	}
+
+	if _, err := p.GetWithClaims(req, &jwt.StandardClaims{}); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+func TestGetClaimsRootExpired(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"data": map[string]interface{}{
+			"exp": time.Now().Add(-1 * time.Hour).Unix(),
+		},
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:    sampleKeyfunc,
+		ClaimsRoot: "data",
+	})
+
+	if _, err := p.Get(req); !errors.Is(err, jaywt.ErrTokenExpired) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrTokenExpired)
+	}
+}
+
+func TestGetClaimsRootMissing(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:    sampleKeyfunc,
+		ClaimsRoot: "data",
+	})
+
+	if _, err := p.Get(req); err != jaywt.ErrClaimsRootMissing {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrClaimsRootMissing)
+	}
+}
+
+func TestGetRevokedKIDs(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject}, withHeader("kid", sampleKID))
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:     sampleKeyfunc,
+		RevokedKIDs: []string{sampleKID},
+	})
+
+	if _, err := p.Get(req); err != jaywt.ErrRevokedKID {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrRevokedKID)
+	}
+
+	p.SetRevokedKIDs(nil)
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+	}
+
+	p.SetRevokedKIDs([]string{"other", sampleKID})
+	if _, err := p.Get(req); err != jaywt.ErrRevokedKID {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrRevokedKID)
+	}
+}
+
+func TestGetDetachedPayload(t *testing.T) {
+	payload := `{"sub":"` + sampleSubject + `"}`
+	req := detachedRequest(t, jwt.MapClaims{"sub": sampleSubject}, nil)
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		DetachedPayload: func(_ *http.Request) ([]byte, error) {
+			return []byte(payload), nil
+		},
+	})
+
+	token, err := p.Get(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sub := token.Claims.(jwt.MapClaims)["sub"]; sub != sampleSubject {
+		t.Errorf("Got %v, want %s", sub, sampleSubject)
+	}
+
+	payload = `{"sub":"someone else"}`
+	if _, err = p.Get(req); err == nil {
+		t.Error("Tampered payload should fail verification")
+	}
+}
+
+func TestGetDetachedPayloadUnencoded(t *testing.T) {
+	req := detachedRequest(t, jwt.MapClaims{"sub": sampleSubject}, map[string]interface{}{"b64": false})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		DetachedPayload: func(_ *http.Request) ([]byte, error) {
+			return []byte(`{"sub":"` + sampleSubject + `"}`), nil
+		},
+	})
+
+	if _, err := p.Get(req); err != jaywt.ErrUnsupportedB64False {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrUnsupportedB64False)
+	}
+}
+
+var unencodedPayloadTable = []string{
+	`{"sub":"` + sampleSubject + `"}`,
+	`{"sub":"` + sampleSubject + `","iss":"example.com"}`,
+	"asdf1234",
+}
+
+func TestGetUnencodedPayload(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+	header := jwt.EncodeSegment([]byte(`{"alg":"HS256","b64":false,"crit":["b64"]}`))
+
+	for _, payload := range unencodedPayloadTable {
+		sig, err := jwt.SigningMethodHS256.Sign(header+"."+payload, []byte(sampleSecret))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+header+"."+payload+"."+sig)
+		if _, err = p.Get(req); err != jaywt.ErrUnsupportedB64False {
+			t.Errorf("%s: Got %v, want %v", payload, err, jaywt.ErrUnsupportedB64False)
+		}
+	}
+}
+
+func TestGetDetachedPayloadMissing(t *testing.T) {
+	req := detachedRequest(t, jwt.MapClaims{"sub": sampleSubject}, nil)
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	if _, err := p.Get(req); err == nil {
+		t.Error("Detached token without DetachedPayload should fail")
+	}
+}
+
+func TestGetRequireTLS(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:    sampleKeyfunc,
+		RequireTLS: true,
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+
+	if _, err := p.Get(req); err != jaywt.ErrInsecureTransport {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrInsecureTransport)
+	}
+
+	req.TLS = &tls.ConnectionState{}
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetRequireTLSForwardedProto(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+	req.Header.Set("X-Forwarded-Proto", "https")
+
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:    sampleKeyfunc,
+		RequireTLS: true,
+	})
+	if _, err := p.Get(req); err != jaywt.ErrInsecureTransport {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrInsecureTransport)
+	}
+
+	p.Options.TrustForwardedProto = true
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+	}
+
+	req.Header.Set("X-Forwarded-Proto", "http")
+	if _, err := p.Get(req); err != jaywt.ErrInsecureTransport {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrInsecureTransport)
+	}
+}
+
+var claimValidators = map[string]func(value interface{}) error{
+	"tenant_id": func(value interface{}) error {
+		if s, _ := value.(string); s == "" {
+			return errors.New("must be a non-empty string")
+		}
+
+		return nil
+	},
+}
+
+func TestGetClaimValidators(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:         sampleKeyfunc,
+		ClaimValidators: claimValidators,
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":       sampleSubject,
+		"tenant_id": "acme",
+	})
+
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+	}
+}
+
+var claimValidatorsTableBad = []jwt.MapClaims{
+	{"sub": sampleSubject},
+	{"sub": sampleSubject, "tenant_id": ""},
+	{"sub": sampleSubject, "tenant_id": 1234},
+}
+
+func TestGetClaimValidatorsBad(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:         sampleKeyfunc,
+		ClaimValidators: claimValidators,
+	})
+
+	for _, claims := range claimValidatorsTableBad {
+		_, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, claims))
+		if !errors.Is(err, jaywt.ErrClaimInvalid) {
+			t.Errorf("%v: Got %v, want %v", claims, err, jaywt.ErrClaimInvalid)
+			continue
+		}
+
+		if !strings.Contains(err.Error(), "tenant_id") {
+			t.Errorf("%v: Error %q should name the claim", claims, err)
+		}
+	}
+}
+
+func TestValidateString(t *testing.T) {
+	raw := sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+		"iss": "https://example.com",
+	})
+
+	token, err := jaywt.ValidateString(raw, &jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Issuer:  "https://example.com",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sub := token.Claims.(jwt.MapClaims)["sub"]; sub != sampleSubject {
+		t.Errorf("Got %v, want %s", sub, sampleSubject)
+	}
+
+	_, err = jaywt.ValidateString(raw, &jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Issuer:  "https://evil.example.com",
+	})
+	if err != jaywt.ErrInvalidIssuer {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrInvalidIssuer)
+	}
+}
+
+func TestValidateStringBad(t *testing.T) {
+	for _, raw := range []string{"", "asdf", headerTokenOk + "x"} {
+		if _, err := jaywt.ValidateString(raw, &jaywt.Options{Keyfunc: sampleKeyfunc}); err == nil {
+			t.Errorf("%q: Error was expected, got nil", raw)
+		}
+	}
+}
+
+func TestExtractValidateRaw(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+
+	raw, err := p.Extract(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := p.ValidateRaw(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sub := token.Claims.(jwt.MapClaims)["sub"]; sub != sampleSubject {
+		t.Errorf("Got %v, want %s", sub, sampleSubject)
+	}
+}
+
+func TestExtractValidateRawBad(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	if _, err := p.Extract(httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+
+	for _, raw := range []string{"", "asdf", headerTokenOk + "x"} {
+		if _, err := p.ValidateRaw(raw); err == nil {
+			t.Errorf("%q: Error was expected, got nil", raw)
+		}
+	}
+}
+
+func TestGetMaxAge(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		MaxAge:  5 * time.Minute,
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"iat": time.Now().Add(-1 * time.Minute).Unix(),
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	})
+
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+	}
+}
+
+var maxAgeTableBad = []jwt.MapClaims{
+	{"iat": time.Now().Add(-10 * time.Minute).Unix(), "exp": time.Now().Add(1 * time.Hour).Unix()},
+	{"exp": time.Now().Add(1 * time.Hour).Unix()},
+}
+
+func TestGetMaxAgeBad(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		MaxAge:  5 * time.Minute,
+	})
+
+	for _, claims := range maxAgeTableBad {
+		req := sampleRequest(t, jwt.SigningMethodHS256, claims)
+		if _, err := p.Get(req); err != jaywt.ErrTokenTooOld {
+			t.Errorf("%v: Got %v, want %v", claims, err, jaywt.ErrTokenTooOld)
+		}
+	}
+}
+
+var criticalTable = []struct {
+	crit interface{}
+	ok   bool
+}{
+	{[]string{"b64"}, true},
+	{[]string{"exp"}, true},
+	{[]string{"b64", "exp"}, true},
+	{[]string{"unknown"}, false},
+	{[]string{"exp", "unknown"}, false},
+	{[]string{}, false},
+	{"exp", false},
+	{[]interface{}{1234}, false},
+}
+
+func TestGetCritical(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:       sampleKeyfunc,
+		KnownCritical: []string{"exp"},
+	})
+
+	for _, c := range criticalTable {
+		req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject},
+			withHeader("crit", c.crit), withHeader("exp", time.Now().Add(1*time.Hour).Unix()))
+		_, err := p.Get(req)
+		if c.ok && err != nil {
+			t.Errorf("%v: %v", c.crit, err)
+		}
+
+		if !c.ok && err != jaywt.ErrUnsupportedCritical {
+			t.Errorf("%v: Got %v, want %v", c.crit, err, jaywt.ErrUnsupportedCritical)
+		}
+	}
+}
+
+func TestGetNestedDecrypt(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:       sampleKeyfunc,
+		NestedDecrypt: sampleDecrypt,
+	})
+	inner := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+sampleJWE(strings.TrimPrefix(inner.Header.Get("Authorization"), "Bearer ")))
+
+	token, err := p.Get(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sub := token.Claims.(jwt.MapClaims)["sub"]; sub != sampleSubject {
+		t.Errorf("Got %v, want %s", sub, sampleSubject)
+	}
+
+	if _, err = p.Get(inner); err != nil {
+		t.Errorf("Unencrypted token: %v", err)
+	}
+}
+
+var nestedDecryptTableBad = []struct {
+	jwe        string
+	decryption bool
+}{
+	{"a.b.c.d.e", true},
+	{sampleJWE("asdf1234"), true},
+	{sampleJWE(headerTokenOk), false},
+}
+
+func TestGetNestedDecryptBad(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:       sampleKeyfunc,
+		NestedDecrypt: sampleDecrypt,
+	})
+
+	for _, c := range nestedDecryptTableBad {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set("Authorization", "Bearer "+c.jwe)
+		_, err := p.Get(req)
+		if err == nil {
+			t.Errorf("%s: Error was expected, got nil", c.jwe)
+			continue
+		}
+
+		if errors.Is(err, jaywt.ErrDecryptionFailed) != c.decryption {
+			t.Errorf("%s: Got %v, want decryption failure %t", c.jwe, err, c.decryption)
+		}
+	}
+}
+
+var audienceByPrefixTable = []struct {
+	path string
+	aud  string
+}{
+	{"/billing/invoices", "billing"},
+	{"/billing/admin/users", "billing-admin"},
+	{"/billing", "default"},
+	{"/users", "default"},
+}
+
+func TestGetAudienceByPrefix(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:  sampleKeyfunc,
+		Audience: "default",
+		AudienceByPrefix: map[string]string{
+			"/billing/":       "billing",
+			"/billing/admin/": "billing-admin",
+		},
+	})
+
+	for _, c := range audienceByPrefixTable {
+		for _, aud := range []string{"default", "billing", "billing-admin"} {
+			req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"aud": aud})
+			req.URL.Path = c.path
+
+			_, err := p.Get(req)
+			if aud == c.aud && err != nil {
+				t.Errorf("%s with %s: %v", c.path, aud, err)
+			}
+
+			if aud != c.aud && err != jaywt.ErrInvalidAudience {
+				t.Errorf("%s with %s: Got %v, want %v", c.path, aud, err, jaywt.ErrInvalidAudience)
+			}
+		}
+	}
+}
+
+var normalizeURLClaimsTable = []struct {
+	claims jwt.MapClaims
+	ok     bool
+}{
+	{jwt.MapClaims{"iss": "https://idp.example.com", "aud": "https://api.example.com/v1"}, true},
+	{jwt.MapClaims{"iss": "https://idp.example.com/", "aud": "https://api.example.com/v1/"}, true},
+	{jwt.MapClaims{"iss": "HTTPS://IdP.Example.com", "aud": []interface{}{"web", "https://API.example.com/v1"}}, true},
+	{jwt.MapClaims{"iss": "https://idp.example.com/tenant", "aud": "https://api.example.com/v1"}, false},
+	{jwt.MapClaims{"iss": "https://idp.example.com", "aud": "https://api.example.com/V1"}, false},
+	{jwt.MapClaims{"iss": "https://evil.example.com", "aud": "https://api.example.com/v1"}, false},
+	{jwt.MapClaims{"aud": "https://api.example.com/v1"}, false},
+}
+
+func TestGetNormalizeURLClaims(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:            sampleKeyfunc,
+		Issuer:             "https://idp.example.com/",
+		Audience:           "https://api.example.com/v1",
+		NormalizeURLClaims: true,
+	})
+
+	for _, c := range normalizeURLClaimsTable {
+		_, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, c.claims))
+		if c.ok && err != nil {
+			t.Errorf("%v: %v", c.claims, err)
+		}
+
+		if !c.ok && err == nil {
+			t.Errorf("%v: Error was expected, got nil", c.claims)
+		}
+	}
+}
+
+func TestGetNormalizeURLClaimsOff(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Issuer:  "https://idp.example.com",
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"iss": "https://idp.example.com/"})
+	if _, err := p.Get(req); err != jaywt.ErrInvalidIssuer {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrInvalidIssuer)
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	}).SignedString([]byte(sampleSecret))
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := p.Get(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkFromAuthHeader(b *testing.B) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", headerOk)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := jaywt.FromAuthHeader(req); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestGetTokenPrefix(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:     sampleKeyfunc,
+		TokenPrefix: "v2.",
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+	raw := strings.TrimPrefix(req.Header.Get("Authorization"), "Bearer ")
+
+	req.Header.Set("Authorization", "Bearer v2."+raw)
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+	}
+
+	for _, bad := range []string{raw, "v1." + raw, "v2" + raw} {
+		req.Header.Set("Authorization", "Bearer "+bad)
+		if _, err := p.Get(req); err != jaywt.ErrBadPrefix {
+			t.Errorf("%s: Got %v, want %v", bad, err, jaywt.ErrBadPrefix)
+		}
+	}
+}
+
+func TestGetResultTimings(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: func(token *jwt.Token) (interface{}, error) {
+			time.Sleep(10 * time.Millisecond)
+			return sampleKeyfunc(token)
+		},
+		Timings: true,
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+
+	res, err := p.GetResult(req, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Timings.KeySelection < 10*time.Millisecond {
+		t.Errorf("Got %v, want at least 10ms", res.Timings.KeySelection)
+	}
+
+	if res.Timings.Verification <= 0 || res.Timings.Verification >= res.Timings.KeySelection {
+		t.Errorf("Got %v, want less than the key selection", res.Timings.Verification)
+	}
+
+	p.Options.Timings = false
+	if res, err = p.GetResult(req, jwt.MapClaims{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if res.Timings != (jaywt.Timings{}) {
+		t.Errorf("Got %+v, want no timings", res.Timings)
+	}
+}
+
+func TestGetContextCancelled(t *testing.T) {
+	calls := 0
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: func(token *jwt.Token) (interface{}, error) {
+			calls++
+			return sampleKeyfunc(token)
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+	if _, err := p.Get(req.WithContext(ctx)); !errors.Is(err, jaywt.ErrContextCancelled) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrContextCancelled)
+	}
+
+	if calls != 0 {
+		t.Errorf("Keyfunc calls: Got %d, want 0", calls)
+	}
+
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+	}
+}
+
+// Helper functions
+// ---
+
+// tokenOption customizes the tokens signed by sampleRaw and sampleRequest.
+type tokenOption func(token *jwt.Token, key *interface{})
+
+// withKey signs the token with the key instead of sampleSecret.
+func withKey(key interface{}) tokenOption {
+	return func(_ *jwt.Token, k *interface{}) {
+		*k = key
+	}
+}
+
+// withHeader sets the token's header, or removes it if the value is nil.
+func withHeader(name string, value interface{}) tokenOption {
+	return func(token *jwt.Token, _ *interface{}) {
+		if value == nil {
+			delete(token.Header, name)
+		} else {
+			token.Header[name] = value
+		}
+	}
+}
+
+// sampleRaw signs the claims with sampleSecret, unless customized.
+func sampleRaw(t *testing.T, method jwt.SigningMethod, claims jwt.Claims, opts ...tokenOption) string {
+	token := jwt.NewWithClaims(method, claims)
+	var key interface{} = []byte(sampleSecret)
+	for _, opt := range opts {
+		opt(token, &key)
+	}
+
+	raw, err := token.SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return raw
+}
+
+// sampleRequest makes a request with the token of sampleRaw.
+func sampleRequest(t *testing.T, method jwt.SigningMethod, claims jwt.Claims, opts ...tokenOption) *http.Request {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+sampleRaw(t, method, claims, opts...))
+	return req
+}
+
+// typRequest signs a token with the 'typ' header, or without it if empty.
+func typRequest(t *testing.T, typ string) *http.Request {
+	var value interface{}
+	if typ != "" {
+		value = typ
+	}
+
+	return sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject}, withHeader("typ", value))
+}
+
+// detachedRequest signs the claims, then removes the payload segment.
+func detachedRequest(t *testing.T, claims jwt.Claims, header map[string]interface{}) *http.Request {
+	var opts []tokenOption
+	for k, v := range header {
+		opts = append(opts, withHeader(k, v))
+	}
+
+	parts := strings.Split(sampleRaw(t, jwt.SigningMethodHS256, claims, opts...), ".")
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer "+parts[0]+".."+parts[2])
+	return req
+}
+
+// sampleJWE wraps the inner token in a fake compact JWE, whose ciphertext is
+// just the encoded inner token.
+func sampleJWE(inner string) string {
+	return jwt.EncodeSegment([]byte(`{"alg":"dir","enc":"A256GCM","cty":"JWT"}`)) + "..." + jwt.EncodeSegment([]byte(inner)) + ".tag"
+}
+
+// sampleDecrypt "decrypts" tokens made by sampleJWE.
+func sampleDecrypt(jwe string) (string, error) {
+	inner, err := jwt.DecodeSegment(strings.Split(jwe, ".")[3])
+	if err != nil {
+		return "", err
+	}
+
+	return string(inner), nil
+}
+
+func mustGenerateRSAKey() *rsa.PrivateKey {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	return key
+}
+
+func sampleKeyfunc(_ *jwt.Token) (interface{}, error) {
+	return []byte(sampleSecret), nil
+}
+
+func badKeyfunc(_ *jwt.Token) (interface{}, error) {
+	return nil, errors.New("Keyfunc error")
+}
+
+func unavailableKeyfunc(_ *jwt.Token) (interface{}, error) {
+	return nil, jaywt.ErrKeyUnavailable
 }
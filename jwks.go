@@ -0,0 +1,565 @@
+package jaywt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"io"
+	"log/slog"
+	"math/big"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// jwksMaxBytes is the maximum size of a document fetched by fetchURL.
+const jwksMaxBytes = 1 << 20
+
+// jwksRetryInterval is how long to wait before retrying a failed refetch of
+// JWKSURL, while the previous keys are used.
+const jwksRetryInterval = 1 * time.Minute
+
+// jwk is a JSON Web Key, as defined by RFC 7517.
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	// RSA keys
+	N string `json:"n"`
+	E string `json:"e"`
+	// EC and OKP keys
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+	// Symmetric keys
+	K string `json:"k"`
+	// Private keys
+	D string `json:"d"`
+	// X.509 certificate chain, leaf first
+	X5c []string `json:"x5c"`
+}
+
+// jwkSet is a JSON Web Key Set, as defined by RFC 7517.
+type jwkSet struct {
+	Keys []jwk `json:"keys"`
+}
+
+// NewKeyfuncFromJWKS returns a Keyfunc selecting keys from the supplied JWKS
+// JSON document by the token's 'kid' header. RSA, EC, Ed25519 and symmetric
+// keys are supported. Keys not meant for signatures and keys of other types
+// are skipped, and keys declaring
+// their 'alg' only verify tokens of that algorithm, or fail with
+// ErrAlgMismatchForKID.
+func NewKeyfuncFromJWKS(jwks []byte) (jwt.Keyfunc, error) {
+	return NewKeyfuncFromJWKSWithRoots(jwks, nil)
+}
+
+// NewKeyfuncFromJWKSFile works like NewKeyfuncFromJWKS, but reads the JWKS
+// from the file at the path once, e.g. a pinned snapshot for validating
+// tokens offline. Changes to the file are not reloaded.
+func NewKeyfuncFromJWKSFile(path string) (jwt.Keyfunc, error) {
+	jwks, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading JWKS file: %w", err)
+	}
+
+	return NewKeyfuncFromJWKS(jwks)
+}
+
+// NewKeyfuncFromJWKSWithRoots works like NewKeyfuncFromJWKS, but also
+// verifies the 'x5c' certificate chains of the keys against the supplied
+// root CAs. Keys with a chain take the public key from its leaf certificate.
+// If roots is nil, chains are not verified.
+func NewKeyfuncFromJWKSWithRoots(jwks []byte, roots *x509.CertPool) (jwt.Keyfunc, error) {
+	keys, err := parseJWKS(jwks, roots)
+	if err != nil {
+		return nil, err
+	}
+
+	return keysKeyfunc(keys), nil
+}
+
+// NewKeyfuncByIssuer returns a Keyfunc selecting keys from the supplied JWKS
+// JSON documents of each issuer by the token's 'iss' claim and 'kid' header
+// together, since issuers may reuse key IDs. Tokens from other issuers fail
+// with ErrInvalidIssuer, and tokens naming the key of another issuer with
+// ErrAmbiguousKey.
+func NewKeyfuncByIssuer(jwks map[string][]byte) (jwt.Keyfunc, error) {
+	sets := make(map[string]map[string]jwksKey, len(jwks))
+	for iss, doc := range jwks {
+		keys, err := parseJWKS(doc, nil)
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing keys of issuer '%s': %w", iss, err)
+		}
+
+		sets[iss] = keys
+	}
+
+	return func(token *jwt.Token) (interface{}, error) {
+		claims, err := claimsMap(token)
+		if err != nil {
+			return nil, err
+		}
+
+		iss, _ := claims["iss"].(string)
+		keys, ok := sets[iss]
+		if !ok {
+			return nil, ErrInvalidIssuer
+		}
+
+		kid, _ := token.Header["kid"].(string)
+		if key, ok := keys[kid]; ok {
+			return key.verifying(token)
+		}
+
+		for other, keys := range sets {
+			if _, ok := keys[kid]; ok && other != iss {
+				return nil, ErrAmbiguousKey
+			}
+		}
+
+		return nil, fmt.Errorf("%w '%s'", ErrUnknownKID, kid)
+	}, nil
+}
+
+// Warm fetches the keys from Options.JWKSURL, or of every issuer of a Core
+// from NewFederation, so the first request doesn't pay for it, e.g. in
+// a readiness probe. It returns an error if they can't be fetched. Without
+// JWKSURL, it does nothing.
+func (m *Core) Warm(ctx context.Context) error {
+	for _, issuer := range m.issuers {
+		if err := issuer.jwks.refresh(ctx); err != nil {
+			return err
+		}
+	}
+
+	if m.jwks == nil {
+		return nil
+	}
+
+	return m.jwks.refresh(ctx)
+}
+
+// Helper functions
+// ---
+
+// remoteJWKS keeps the keys fetched from Options.JWKSURL, or from url if
+// set, e.g. for an issuer of a federation, reading through Options.JWKSCache.
+// The options are shared with the Core, so changes to them apply.
+type remoteJWKS struct {
+	options *Options
+	url     string
+
+	mu     sync.Mutex
+	keys   map[string]jwksKey
+	next   time.Time
+	forced time.Time
+}
+
+// keyfunc selects the key by the token's 'kid', fetching the keys when stale.
+// Unknown key IDs force a refetch, at most once per JWKSForcedRefresh, in
+// case the keys were rotated. It fails with ErrKeyUnavailable if there are no
+// keys to select from, and ErrUnknownKID if the key ID is still unknown.
+func (j *remoteJWKS) keyfunc(token *jwt.Token) (interface{}, error) {
+	kid, _ := token.Header["kid"].(string)
+	ctx := context.Background()
+
+	j.mu.Lock()
+	if time.Now().After(j.next) {
+		j.fetch(ctx, false)
+	}
+
+	if _, ok := j.keys[kid]; !ok && j.keys != nil && time.Since(j.forced) >= j.options.JWKSForcedRefresh {
+		j.forced = time.Now()
+		j.fetch(ctx, true)
+	}
+	keys := j.keys
+	j.mu.Unlock()
+
+	if keys == nil {
+		return nil, ErrKeyUnavailable
+	}
+
+	key, ok := keys[kid]
+	if !ok {
+		return nil, fmt.Errorf("%w '%s'", ErrUnknownKID, kid)
+	}
+
+	return key.verifying(token)
+}
+
+// has reports whether the cached keys have the key ID, without fetching them.
+func (j *remoteJWKS) has(kid string) bool {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	_, ok := j.keys[kid]
+	return ok
+}
+
+func (j *remoteJWKS) refresh(ctx context.Context) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	return j.fetch(ctx, false)
+}
+
+// fetch replaces the keys with the ones from the URL, skipping JWKSCache if
+// forced. On failure, the previous keys are kept and retried later. Must be
+// called with mu held.
+func (j *remoteJWKS) fetch(ctx context.Context, force bool) error {
+	keys, err := j.get(ctx, force)
+	if err != nil {
+		j.next = time.Now().Add(jwksRetryInterval)
+		return err
+	}
+
+	j.keys = keys
+	j.next = time.Now().Add(j.options.JWKSRefresh)
+	return nil
+}
+
+// get returns the keys from JWKSCache, or from the URL if they aren't
+// cached or force is set. Cache failures are logged, and don't fail fetching
+// the keys.
+func (j *remoteJWKS) get(ctx context.Context, force bool) (map[string]jwksKey, error) {
+	o, url := j.options, j.url
+	if url == "" {
+		url = o.JWKSURL
+	}
+
+	if !force {
+		data, err := o.JWKSCache.Get(ctx, url)
+		if err != nil {
+			o.Logger.LogAttrs(ctx, slog.LevelWarn, "JWKS cache read failed", slog.Any(logKeyError, err))
+		}
+
+		if data != nil {
+			return parseJWKS(data, o.RootCAs)
+		}
+	}
+
+	data, err := fetchURL(ctx, o.HTTPClient, url)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching JWKS: %w", err)
+	}
+
+	keys, err := parseJWKS(data, o.RootCAs)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = o.JWKSCache.Set(ctx, url, data, o.JWKSRefresh); err != nil {
+		o.Logger.LogAttrs(ctx, slog.LevelWarn, "JWKS cache write failed", slog.Any(logKeyError, err))
+	}
+
+	return keys, nil
+}
+
+// fetchURL returns the body of a successful GET request to the URL, up to
+// jwksMaxBytes.
+func fetchURL(ctx context.Context, client *http.Client, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, errors.New(res.Status)
+	}
+
+	return io.ReadAll(io.LimitReader(res.Body, jwksMaxBytes))
+}
+
+// jwksKey is a key parsed from a JWKS, with the algorithm its JWK declares.
+type jwksKey struct {
+	key interface{}
+	alg string
+}
+
+// verifying returns the key verifying the token, or ErrAlgMismatchForKID if
+// the JWK declares another algorithm than the token's 'alg' header.
+func (k jwksKey) verifying(token *jwt.Token) (interface{}, error) {
+	if alg, _ := token.Header["alg"].(string); k.alg != "" && k.alg != alg {
+		return nil, ErrAlgMismatchForKID
+	}
+
+	return k.key, nil
+}
+
+// parseJWKS parses a JWKS JSON document into keys indexed by their ID.
+// Certificate chains are verified against roots, unless it's nil.
+func parseJWKS(data []byte, roots *x509.CertPool) (map[string]jwksKey, error) {
+	var set jwkSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("Error parsing JWKS: %w", err)
+	}
+
+	keys := make(map[string]jwksKey, len(set.Keys))
+	for _, k := range set.Keys {
+		if k.Use != "" && k.Use != "sig" {
+			continue
+		}
+
+		var key interface{}
+		var err error
+		if len(k.X5c) > 0 {
+			key, err = k.certKey(roots)
+		} else {
+			key, err = k.key()
+		}
+
+		if errors.Is(err, errUnsupportedKey) {
+			continue
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("Error parsing JWK %s: %w", k.Kid, err)
+		}
+
+		keys[k.Kid] = jwksKey{key: key, alg: k.Alg}
+	}
+
+	return keys, nil
+}
+
+// keysKeyfunc returns a Keyfunc selecting from the keys by the token's 'kid'.
+func keysKeyfunc(keys map[string]jwksKey) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("%w '%s'", ErrUnknownKID, kid)
+		}
+
+		return key.verifying(token)
+	}
+}
+
+// key returns the key the JWK describes.
+func (k *jwk) key() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		return k.rsaKey()
+	case "EC":
+		return k.ecKey()
+	case "OKP":
+		return k.okpKey()
+	case "oct":
+		return decodeJWKField("k", k.K)
+	}
+
+	return nil, fmt.Errorf("%w type '%s'", errUnsupportedKey, k.Kty)
+}
+
+// errUnsupportedKey is wrapped by the errors of JWKs of types, or OKP curves,
+// that aren't supported, which parseJWKS skips.
+var errUnsupportedKey = errors.New("Unsupported key")
+
+func (k *jwk) rsaKey() (*rsa.PublicKey, error) {
+	n, err := decodeJWKField("n", k.N)
+	if err != nil {
+		return nil, err
+	}
+
+	e, err := decodeJWKField("e", k.E)
+	if err != nil {
+		return nil, err
+	}
+
+	exponent := new(big.Int).SetBytes(e)
+	if !exponent.IsInt64() || exponent.Int64() > 1<<31-1 {
+		return nil, errors.New("RSA exponent is too large")
+	}
+
+	return &rsa.PublicKey{
+		N: new(big.Int).SetBytes(n),
+		E: int(exponent.Int64()),
+	}, nil
+}
+
+func (k *jwk) ecKey() (*ecdsa.PublicKey, error) {
+	var curve elliptic.Curve
+	switch k.Crv {
+	case "P-256":
+		curve = elliptic.P256()
+	case "P-384":
+		curve = elliptic.P384()
+	case "P-521":
+		curve = elliptic.P521()
+	default:
+		return nil, fmt.Errorf("Unsupported curve '%s'", k.Crv)
+	}
+
+	x, err := decodeJWKField("x", k.X)
+	if err != nil {
+		return nil, err
+	}
+
+	y, err := decodeJWKField("y", k.Y)
+	if err != nil {
+		return nil, err
+	}
+
+	key := &ecdsa.PublicKey{
+		Curve: curve,
+		X:     new(big.Int).SetBytes(x),
+		Y:     new(big.Int).SetBytes(y),
+	}
+
+	if !curve.IsOnCurve(key.X, key.Y) {
+		return nil, errors.New("EC point is not on the curve")
+	}
+
+	return key, nil
+}
+
+// okpKey returns the Ed25519 key of the OKP JWK (RFC 8037). Other OKP
+// curves, e.g. X25519 for key agreement, aren't supported.
+func (k *jwk) okpKey() (ed25519.PublicKey, error) {
+	if k.Crv == "" {
+		return nil, errors.New("Field 'crv' is missing")
+	}
+
+	if k.Crv != "Ed25519" {
+		return nil, fmt.Errorf("%w curve '%s'", errUnsupportedKey, k.Crv)
+	}
+
+	x, err := decodeJWKField("x", k.X)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(x) != ed25519.PublicKeySize {
+		return nil, errors.New("Ed25519 key has the wrong size")
+	}
+
+	return ed25519.PublicKey(x), nil
+}
+
+// certKey returns the public key of the leaf certificate in the JWK's
+// 'x5c' chain, verifying the chain against roots unless it's nil.
+func (k *jwk) certKey(roots *x509.CertPool) (interface{}, error) {
+	certs := make([]*x509.Certificate, len(k.X5c))
+	for i, c := range k.X5c {
+		// Unlike other fields, certificates use standard base64
+		der, err := base64.StdEncoding.DecodeString(c)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding certificate: %w", err)
+		}
+
+		if certs[i], err = x509.ParseCertificate(der); err != nil {
+			return nil, fmt.Errorf("Error parsing certificate: %w", err)
+		}
+	}
+
+	if roots != nil {
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+
+		_, err := certs[0].Verify(x509.VerifyOptions{
+			Roots:         roots,
+			Intermediates: intermediates,
+			KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("Error verifying certificate chain: %w", err)
+		}
+	}
+
+	// If the raw key is present too, it must be the certified one
+	if k.Kty == "RSA" && k.N != "" {
+		raw, err := k.rsaKey()
+		if err != nil {
+			return nil, err
+		}
+
+		if cert, ok := certs[0].PublicKey.(*rsa.PublicKey); !ok || cert.N.Cmp(raw.N) != 0 || cert.E != raw.E {
+			return nil, errors.New("Certificate does not match the key")
+		}
+	}
+
+	return certs[0].PublicKey, nil
+}
+
+// publicJWK parses a JWK embedded in a JOSE header, which must hold an
+// asymmetric public key.
+func publicJWK(value interface{}) (*jwk, interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	k := new(jwk)
+	if err = json.Unmarshal(data, k); err != nil {
+		return nil, nil, err
+	}
+
+	if k.Kty == "oct" || k.D != "" {
+		return nil, nil, errors.New("JWK must be a public key")
+	}
+
+	key, err := k.key()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return k, key, nil
+}
+
+// thumbprint returns the JWK's SHA-256 thumbprint, as defined by RFC 7638.
+func (k *jwk) thumbprint() (string, error) {
+	var members map[string]string
+	switch k.Kty {
+	case "RSA":
+		members = map[string]string{"e": k.E, "kty": k.Kty, "n": k.N}
+	case "EC":
+		members = map[string]string{"crv": k.Crv, "kty": k.Kty, "x": k.X, "y": k.Y}
+	case "OKP":
+		members = map[string]string{"crv": k.Crv, "kty": k.Kty, "x": k.X}
+	default:
+		return "", fmt.Errorf("Unsupported key type '%s'", k.Kty)
+	}
+
+	// Map keys are marshaled sorted, as the thumbprint requires
+	data, err := json.Marshal(members)
+	if err != nil {
+		return "", err
+	}
+
+	digest := sha256.Sum256(data)
+	return jwt.EncodeSegment(digest[:]), nil
+}
+
+// decodeJWKField decodes a required base64url encoded JWK field.
+func decodeJWKField(name, value string) ([]byte, error) {
+	if value == "" {
+		return nil, fmt.Errorf("Field '%s' is missing", name)
+	}
+
+	decoded, err := jwt.DecodeSegment(value)
+	if err != nil {
+		return nil, fmt.Errorf("Error decoding field '%s': %w", name, err)
+	}
+
+	return decoded, nil
+}
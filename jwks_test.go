@@ -0,0 +1,643 @@
+package jaywt_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const sampleKID = "key-1"
+
+func TestNewKeyfuncFromJWKSRSA(t *testing.T) {
+	jwks := sampleJWKS(t, rsaJWK(sampleKID, &sampleRSAKey.PublicKey))
+	keyfunc, err := jaywt.NewKeyfuncFromJWKS(jwks)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	signed := sampleRaw(t, jwt.SigningMethodRS256, jwt.MapClaims{"sub": sampleSubject}, withKey(sampleRSAKey), withHeader("kid", sampleKID))
+	if _, err = jwt.Parse(signed, keyfunc); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewKeyfuncFromJWKSFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, sampleJWKS(t, rsaJWK(sampleKID, &sampleRSAKey.PublicKey)), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	keyfunc, err := jaywt.NewKeyfuncFromJWKSFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:       keyfunc,
+		SigningMethod: jwt.SigningMethodRS256,
+	})
+	if _, err = p.Get(jwksRequest(t)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewKeyfuncFromJWKSFileMissing(t *testing.T) {
+	if _, err := jaywt.NewKeyfuncFromJWKSFile(filepath.Join(t.TempDir(), "missing.json")); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+func TestNewKeyfuncFromJWKSEC(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := sampleJWKS(t, map[string]string{
+		"kty": "EC",
+		"kid": sampleKID,
+		"crv": "P-256",
+		"x":   jwt.EncodeSegment(key.X.Bytes()),
+		"y":   jwt.EncodeSegment(key.Y.Bytes()),
+	})
+	keyfunc, err := jaywt.NewKeyfuncFromJWKS(jwks)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	signed := sampleRaw(t, jwt.SigningMethodES256, jwt.MapClaims{"sub": sampleSubject}, withKey(key), withHeader("kid", sampleKID))
+	if _, err = jwt.Parse(signed, keyfunc); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewKeyfuncFromJWKSEd25519(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Keys of unsupported types don't break the others
+	jwks := sampleJWKS(t,
+		rsaJWK(sampleKID, &sampleRSAKey.PublicKey),
+		map[string]string{"kty": "OKP", "kid": "ed", "crv": "Ed25519", "x": jwt.EncodeSegment(pub)},
+		map[string]string{"kty": "OKP", "kid": "ed448", "crv": "Ed448", "x": "AAAA"},
+		map[string]string{"kty": "AKP", "kid": "pq", "pub": "AAAA"},
+	)
+	keyfunc, err := jaywt.NewKeyfuncFromJWKS(jwks)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:      keyfunc,
+		ValidMethods: []string{"RS256", "EdDSA"},
+	})
+
+	if _, err = p.Get(rsaRequest(t, sampleRSAKey, sampleKID)); err != nil {
+		t.Error(err)
+	}
+
+	req := sampleRequest(t, jaywt.SigningMethodEdDSA, jwt.MapClaims{"sub": sampleSubject}, withKey(key), withHeader("kid", "ed"))
+	if _, err = p.Get(req); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewKeyfuncFromJWKSAlgMismatch(t *testing.T) {
+	jwk := rsaJWK(sampleKID, &sampleRSAKey.PublicKey)
+	jwk["alg"] = "RS256"
+	keyfunc, err := jaywt.NewKeyfuncFromJWKS(sampleJWKS(t, jwk))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:      keyfunc,
+		ValidMethods: []string{"RS256", "PS256"},
+	})
+
+	table := []struct {
+		method jwt.SigningMethod
+		err    error
+	}{
+		{jwt.SigningMethodRS256, nil},
+		{jwt.SigningMethodPS256, jaywt.ErrAlgMismatchForKID},
+	}
+
+	for _, c := range table {
+		req := sampleRequest(t, c.method, jwt.MapClaims{"sub": sampleSubject}, withKey(sampleRSAKey), withHeader("kid", sampleKID))
+		if _, err := p.Get(req); err != c.err {
+			t.Errorf("%s: Got %v, want %v", c.method.Alg(), err, c.err)
+		}
+	}
+}
+
+func TestNewKeyfuncFromJWKSSymmetric(t *testing.T) {
+	jwks := sampleJWKS(t, map[string]string{
+		"kty": "oct",
+		"kid": sampleKID,
+		"k":   jwt.EncodeSegment([]byte(sampleSecret)),
+	})
+	keyfunc, err := jaywt.NewKeyfuncFromJWKS(jwks)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	signed := sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject}, withHeader("kid", sampleKID))
+	if _, err = jwt.Parse(signed, keyfunc); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewKeyfuncFromJWKSUnknownKID(t *testing.T) {
+	jwks := sampleJWKS(t, rsaJWK(sampleKID, &sampleRSAKey.PublicKey))
+	keyfunc, err := jaywt.NewKeyfuncFromJWKS(jwks)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = keyfunc(&jwt.Token{Header: map[string]interface{}{"kid": "nope"}})
+	if !errors.Is(err, jaywt.ErrUnknownKID) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrUnknownKID)
+	}
+}
+
+func TestNewKeyfuncFromJWKSSkipsEncryptionKeys(t *testing.T) {
+	k := rsaJWK(sampleKID, &sampleRSAKey.PublicKey)
+	k["use"] = "enc"
+	keyfunc, err := jaywt.NewKeyfuncFromJWKS(sampleJWKS(t, k))
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	_, err = keyfunc(&jwt.Token{Header: map[string]interface{}{"kid": sampleKID}})
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+var jwksTableBad = []string{
+	`not json`,
+	`{"keys": [{"kty": "RSA", "kid": "a", "e": "AQAB"}]}`,
+	`{"keys": [{"kty": "EC", "kid": "a", "crv": "P-256", "x": "AAAA", "y": "AAAA"}]}`,
+	`{"keys": [{"kty": "EC", "kid": "a", "crv": "P-192", "x": "AAAA", "y": "AAAA"}]}`,
+	`{"keys": [{"kty": "OKP", "kid": "a"}]}`,
+	`{"keys": [{"kty": "OKP", "kid": "a", "crv": "Ed25519", "x": "AAAA"}]}`,
+}
+
+func TestNewKeyfuncFromJWKSBad(t *testing.T) {
+	for _, jwks := range jwksTableBad {
+		_, err := jaywt.NewKeyfuncFromJWKS([]byte(jwks))
+		if err == nil {
+			t.Errorf("%s: Error was expected, got nil", jwks)
+		}
+	}
+}
+
+func TestNewKeyfuncByIssuer(t *testing.T) {
+	otherKey := mustGenerateRSAKey()
+	keyfunc, err := jaywt.NewKeyfuncByIssuer(map[string][]byte{
+		"https://a.example.com": sampleJWKS(t, rsaJWK(sampleKID, &sampleRSAKey.PublicKey)),
+		"https://b.example.com": sampleJWKS(t, rsaJWK(sampleKID, &otherKey.PublicKey), rsaJWK("key-2", &otherKey.PublicKey)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := []struct {
+		iss string
+		kid string
+		key *rsa.PublicKey
+		err error
+	}{
+		{"https://a.example.com", sampleKID, &sampleRSAKey.PublicKey, nil},
+		{"https://b.example.com", sampleKID, &otherKey.PublicKey, nil},
+		{"https://a.example.com", "key-2", nil, jaywt.ErrAmbiguousKey},
+		{"https://a.example.com", "key-3", nil, jaywt.ErrUnknownKID},
+		{"https://evil.example.com", sampleKID, nil, jaywt.ErrInvalidIssuer},
+	}
+
+	for _, c := range table {
+		token := &jwt.Token{
+			Header: map[string]interface{}{"kid": c.kid},
+			Claims: jwt.MapClaims{"iss": c.iss},
+		}
+
+		key, err := keyfunc(token)
+		if !errors.Is(err, c.err) {
+			t.Errorf("%s %s: Got %v, want %v", c.iss, c.kid, err, c.err)
+			continue
+		}
+
+		if c.key != nil && key.(*rsa.PublicKey).N.Cmp(c.key.N) != 0 {
+			t.Errorf("%s %s: Got the wrong key", c.iss, c.kid)
+		}
+	}
+}
+
+func TestNewKeyfuncByIssuerBad(t *testing.T) {
+	if _, err := jaywt.NewKeyfuncByIssuer(map[string][]byte{"https://a.example.com": []byte("{")}); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+func TestNewKeyfuncFromJWKSWithRootsX5c(t *testing.T) {
+	ca, caKey := sampleCA(t)
+	leaf := sampleLeafCert(t, ca, caKey, &sampleRSAKey.PublicKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	k := rsaJWK(sampleKID, &sampleRSAKey.PublicKey)
+	k["x5c"] = base64.StdEncoding.EncodeToString(leaf.Raw)
+	jwks := sampleJWKSWithChain(t, k)
+
+	keyfunc, err := jaywt.NewKeyfuncFromJWKSWithRoots(jwks, roots)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	key, err := keyfunc(&jwt.Token{Header: map[string]interface{}{"kid": sampleKID}})
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if pub, ok := key.(*rsa.PublicKey); !ok || pub.N.Cmp(sampleRSAKey.N) != 0 {
+		t.Error("Key should be the leaf certificate's key")
+	}
+}
+
+func TestNewKeyfuncFromJWKSX5cOnly(t *testing.T) {
+	ca, caKey := sampleCA(t)
+	leaf := sampleLeafCert(t, ca, caKey, &sampleRSAKey.PublicKey)
+
+	jwks := sampleJWKSWithChain(t, map[string]string{
+		"kty": "RSA",
+		"kid": sampleKID,
+		"x5c": base64.StdEncoding.EncodeToString(leaf.Raw),
+	})
+
+	if _, err := jaywt.NewKeyfuncFromJWKS(jwks); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewKeyfuncFromJWKSWithRootsUntrusted(t *testing.T) {
+	ca, caKey := sampleCA(t)
+	other, _ := sampleCA(t)
+	leaf := sampleLeafCert(t, ca, caKey, &sampleRSAKey.PublicKey)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(other)
+
+	jwks := sampleJWKSWithChain(t, map[string]string{
+		"kty": "RSA",
+		"kid": sampleKID,
+		"x5c": base64.StdEncoding.EncodeToString(leaf.Raw),
+	})
+
+	if _, err := jaywt.NewKeyfuncFromJWKSWithRoots(jwks, roots); err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestNewKeyfuncFromJWKSX5cMismatch(t *testing.T) {
+	ca, caKey := sampleCA(t)
+	leaf := sampleLeafCert(t, ca, caKey, &caKey.PublicKey)
+
+	k := rsaJWK(sampleKID, &sampleRSAKey.PublicKey)
+	k["x5c"] = base64.StdEncoding.EncodeToString(leaf.Raw)
+
+	if _, err := jaywt.NewKeyfuncFromJWKS(sampleJWKSWithChain(t, k)); err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestRootCAs(t *testing.T) {
+	ca, caKey := sampleCA(t)
+	other, _ := sampleCA(t)
+	leaf := sampleLeafCert(t, ca, caKey, &sampleRSAKey.PublicKey)
+
+	k := rsaJWK(sampleKID, &sampleRSAKey.PublicKey)
+	k["x5c"] = base64.StdEncoding.EncodeToString(leaf.Raw)
+	jwks := sampleJWKSWithChain(t, k)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(jwks)
+	}))
+	defer server.Close()
+
+	for _, c := range []struct {
+		root  *x509.Certificate
+		valid bool
+	}{{ca, true}, {other, false}} {
+		roots := x509.NewCertPool()
+		roots.AddCert(c.root)
+		p := jaywt.New(&jaywt.Options{
+			JWKSURL:       server.URL,
+			RootCAs:       roots,
+			SigningMethod: jwt.SigningMethodRS256,
+		})
+
+		if _, err := p.Get(jwksRequest(t)); (err == nil) != c.valid {
+			t.Errorf("Got %v, want valid %t", err, c.valid)
+		}
+	}
+}
+
+func TestWarmJWKSURL(t *testing.T) {
+	server, hits := sampleJWKSServer(t, http.StatusOK)
+	defer server.Close()
+
+	p := jaywt.New(&jaywt.Options{
+		JWKSURL:       server.URL,
+		SigningMethod: jwt.SigningMethodRS256,
+	})
+
+	if err := p.Warm(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.Get(jwksRequest(t)); err != nil {
+		t.Error(err)
+	}
+
+	if *hits != 1 {
+		t.Errorf("JWKS fetches: Got %d, want 1", *hits)
+	}
+}
+
+func TestGetJWKSURLLazy(t *testing.T) {
+	server, hits := sampleJWKSServer(t, http.StatusOK)
+	defer server.Close()
+
+	p := jaywt.New(&jaywt.Options{
+		JWKSURL:       server.URL,
+		SigningMethod: jwt.SigningMethodRS256,
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.Get(jwksRequest(t)); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if *hits != 1 {
+		t.Errorf("JWKS fetches: Got %d, want 1", *hits)
+	}
+}
+
+func TestWarmJWKSURLError(t *testing.T) {
+	server, _ := sampleJWKSServer(t, http.StatusInternalServerError)
+	defer server.Close()
+
+	p := jaywt.New(&jaywt.Options{
+		JWKSURL:       server.URL,
+		SigningMethod: jwt.SigningMethodRS256,
+	})
+
+	if err := p.Warm(context.Background()); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+
+	if _, err := p.Get(jwksRequest(t)); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+func TestWarmNoJWKSURL(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	if err := p.Warm(context.Background()); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetJWKSURLRotated(t *testing.T) {
+	newKey := mustGenerateRSAKey()
+	var mu sync.Mutex
+	jwks := sampleJWKS(t, rsaJWK(sampleKID, &sampleRSAKey.PublicKey))
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		hits++
+		w.Write(jwks)
+	}))
+	defer server.Close()
+
+	p := jaywt.New(&jaywt.Options{
+		JWKSURL:       server.URL,
+		SigningMethod: jwt.SigningMethodRS256,
+	})
+	if err := p.Warm(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	mu.Lock()
+	jwks = sampleJWKS(t, rsaJWK(sampleKID, &sampleRSAKey.PublicKey), rsaJWK("key-2", &newKey.PublicKey))
+	mu.Unlock()
+
+	if _, err := p.Get(rsaRequest(t, newKey, "key-2")); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := p.Get(rsaRequest(t, newKey, "key-3")); !errors.Is(err, jaywt.ErrUnknownKID) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrUnknownKID)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if hits != 2 {
+		t.Errorf("JWKS fetches: Got %d, want 2", hits)
+	}
+}
+
+func TestGetJWKSURLForcedRefresh(t *testing.T) {
+	server, hits := sampleJWKSServer(t, http.StatusOK)
+	defer server.Close()
+
+	p := jaywt.New(&jaywt.Options{
+		JWKSURL:           server.URL,
+		JWKSForcedRefresh: 1 * time.Hour,
+		SigningMethod:     jwt.SigningMethodRS256,
+	})
+	if err := p.Warm(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := p.Get(rsaRequest(t, sampleRSAKey, "unknown")); !errors.Is(err, jaywt.ErrUnknownKID) {
+			t.Errorf("Got %v, want %v", err, jaywt.ErrUnknownKID)
+		}
+	}
+
+	if *hits != 2 {
+		t.Errorf("JWKS fetches: Got %d, want 2", *hits)
+	}
+}
+
+func TestGetJWKSURLContextCancelled(t *testing.T) {
+	server, hits := sampleJWKSServer(t, http.StatusOK)
+	defer server.Close()
+
+	p := jaywt.New(&jaywt.Options{
+		JWKSURL:       server.URL,
+		SigningMethod: jwt.SigningMethodRS256,
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := p.Get(jwksRequest(t).WithContext(ctx))
+	if !errors.Is(err, jaywt.ErrContextCancelled) || !errors.Is(err, context.Canceled) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrContextCancelled)
+	}
+
+	if *hits != 0 {
+		t.Errorf("JWKS fetches: Got %d, want 0", *hits)
+	}
+}
+
+// Helper functions
+// ---
+
+// sampleJWKSWithChain marshals the keys, turning their 'x5c' into an array.
+func sampleJWKSWithChain(t *testing.T, keys ...map[string]string) []byte {
+	set := make([]map[string]interface{}, len(keys))
+	for i, k := range keys {
+		set[i] = map[string]interface{}{}
+		for name, value := range k {
+			set[i][name] = value
+		}
+
+		if x5c, ok := k["x5c"]; ok {
+			set[i]["x5c"] = []string{x5c}
+		}
+	}
+
+	data, err := json.Marshal(map[string]interface{}{"keys": set})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func sampleCA(t *testing.T) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Sample CA"},
+		NotBefore:             time.Now().Add(-1 * time.Hour),
+		NotAfter:              time.Now().Add(1 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert, key
+}
+
+func sampleLeafCert(t *testing.T, ca *x509.Certificate, caKey *rsa.PrivateKey, pub *rsa.PublicKey) *x509.Certificate {
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "Sample signing key"},
+		NotBefore:    time.Now().Add(-1 * time.Hour),
+		NotAfter:     time.Now().Add(1 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca, pub, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return cert
+}
+
+// sampleJWKSServer serves the JWKS of sampleRSAKey with the given status,
+// counting the requests.
+func sampleJWKSServer(t *testing.T, status int) (*httptest.Server, *int) {
+	jwks := sampleJWKS(t, rsaJWK(sampleKID, &sampleRSAKey.PublicKey))
+	hits := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		hits++
+		w.WriteHeader(status)
+		w.Write(jwks)
+	}))
+
+	return server, &hits
+}
+
+func jwksRequest(t *testing.T) *http.Request {
+	return rsaRequest(t, sampleRSAKey, sampleKID)
+}
+
+func rsaRequest(t *testing.T, key *rsa.PrivateKey, kid string) *http.Request {
+	return sampleRequest(t, jwt.SigningMethodRS256, jwt.MapClaims{"sub": sampleSubject}, withKey(key), withHeader("kid", kid))
+}
+
+func sampleJWKS(t *testing.T, keys ...map[string]string) []byte {
+	data, err := json.Marshal(map[string]interface{}{"keys": keys})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return data
+}
+
+func rsaJWK(kid string, key *rsa.PublicKey) map[string]string {
+	return map[string]string{
+		"kty": "RSA",
+		"kid": kid,
+		"n":   jwt.EncodeSegment(key.N.Bytes()),
+		"e":   jwt.EncodeSegment(big.NewInt(int64(key.E)).Bytes()),
+	}
+}
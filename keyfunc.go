@@ -0,0 +1,183 @@
+package jaywt
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"os"
+	"sync"
+	"time"
+)
+
+// providerCacheTTL is how long NewHMACKeyfuncProvider reuses a secret.
+const providerCacheTTL = 10 * time.Second
+
+// KeyResolver resolves the keys verifying tokens by their 'kid' and 'alg'
+// headers, for Options.KeyResolver, e.g. from a KMS or over gRPC. The kid is
+// empty for tokens without one. Caching is up to the implementation, which
+// must be safe for concurrent use.
+type KeyResolver interface {
+	Resolve(ctx context.Context, kid, alg string) (interface{}, error)
+}
+
+// NewHMACKeyfuncFromEnv returns a Keyfunc serving the shared secret stored
+// in the given environment variable. If base64Encoded is true, the value is
+// decoded as standard base64 first. It returns an error if the variable is
+// empty or can't be decoded, so a missing secret is caught at startup.
+func NewHMACKeyfuncFromEnv(envVar string, base64Encoded bool) (jwt.Keyfunc, error) {
+	value := os.Getenv(envVar)
+	if value == "" {
+		return nil, fmt.Errorf("Environment variable %s is empty", envVar)
+	}
+
+	secret := []byte(value)
+	if base64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(value)
+		if err != nil {
+			return nil, fmt.Errorf("Error decoding %s: %w", envVar, err)
+		}
+
+		if len(decoded) == 0 {
+			return nil, fmt.Errorf("Environment variable %s decodes to an empty secret", envVar)
+		}
+
+		secret = decoded
+	}
+
+	return func(_ *jwt.Token) (interface{}, error) {
+		return secret, nil
+	}, nil
+}
+
+// NewHMACKeyfuncByKID returns a Keyfunc selecting the shared secret by the
+// token's 'kid' header. Tokens without a 'kid' or with an unknown one fail.
+// The map is copied, so changing it later has no effect.
+func NewHMACKeyfuncByKID(secrets map[string][]byte) jwt.Keyfunc {
+	keys := make(map[string][]byte, len(secrets))
+	for kid, secret := range secrets {
+		keys[kid] = secret
+	}
+
+	return func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		if kid == "" {
+			return nil, errors.New("Token has no 'kid' header")
+		}
+
+		secret, ok := keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("%w '%s'", ErrUnknownKID, kid)
+		}
+
+		return secret, nil
+	}
+}
+
+// NewHMACKeyfuncProvider returns a Keyfunc serving the shared secret returned
+// by the provider, e.g. a secret store rotating it. The secret is cached for
+// a few seconds, so the provider isn't called for every token. Provider
+// errors fail the verification and aren't cached.
+func NewHMACKeyfuncProvider(provider func() ([]byte, error)) jwt.Keyfunc {
+	var (
+		mu      sync.Mutex
+		secret  []byte
+		fetched time.Time
+	)
+
+	return func(_ *jwt.Token) (interface{}, error) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if secret != nil && time.Since(fetched) < providerCacheTTL {
+			return secret, nil
+		}
+
+		next, err := provider()
+		if err != nil {
+			return nil, fmt.Errorf("Error fetching secret: %w", err)
+		}
+
+		if len(next) == 0 {
+			return nil, errors.New("Secret provider returned an empty secret")
+		}
+
+		secret, fetched = next, time.Now()
+		return secret, nil
+	}
+}
+
+// NewRSAKeyfunc returns a Keyfunc serving the supplied public key. RSA keys
+// verify both PKCS #1 v1.5 (RS256, RS384, RS512) and RSA-PSS (PS256, PS384,
+// PS512) signatures, so set Options.SigningMethod to pick between them.
+func NewRSAKeyfunc(pub *rsa.PublicKey) jwt.Keyfunc {
+	return func(_ *jwt.Token) (interface{}, error) {
+		return pub, nil
+	}
+}
+
+// NewEd25519Keyfunc returns a Keyfunc serving the supplied public key. Set
+// Options.SigningMethod to SigningMethodEdDSA to verify with it.
+func NewEd25519Keyfunc(pub ed25519.PublicKey) jwt.Keyfunc {
+	return func(_ *jwt.Token) (interface{}, error) {
+		return pub, nil
+	}
+}
+
+// SetHMACSecret replaces the shared secret verifying tokens, taking precedence
+// over Options.Keyfunc. It is safe to call while tokens are being checked,
+// e.g. from a watcher of a secret store. The previous secret is still
+// accepted for Options.HMACOverlap.
+func (m *Core) SetHMACSecret(secret []byte) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.hmac = hmacSecrets{
+		current:       secret,
+		previous:      m.hmac.current,
+		previousUntil: time.Now().Add(m.Options.HMACOverlap),
+	}
+}
+
+// Helper functions
+// ---
+
+// hmacSecrets are the shared secrets set by SetHMACSecret.
+type hmacSecrets struct {
+	current       []byte
+	previous      []byte
+	previousUntil time.Time
+}
+
+// previousHMACSecret returns the previous shared secret, while it's accepted.
+func (m *Core) previousHMACSecret() []byte {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if m.hmac.previous == nil || time.Now().After(m.hmac.previousUntil) {
+		return nil
+	}
+
+	return m.hmac.previous
+}
+
+// checkKeySize returns ErrWeakKey if the RSA or ECDSA key is smaller than
+// allowed by the options. Other keys pass.
+func (m *Core) checkKeySize(key interface{}) error {
+	switch k := key.(type) {
+	case *rsa.PublicKey:
+		if bits := k.N.BitLen(); bits < m.Options.MinRSAKeySize {
+			return fmt.Errorf("%w: RSA key has %d bits, want at least %d", ErrWeakKey, bits, m.Options.MinRSAKeySize)
+		}
+	case *ecdsa.PublicKey:
+		if bits := k.Curve.Params().BitSize; bits < m.Options.MinECKeySize {
+			return fmt.Errorf("%w: EC curve has %d bits, want at least %d", ErrWeakKey, bits, m.Options.MinECKeySize)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,488 @@
+package jaywt_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+const sampleEnvVar = "JAYWT_TEST_SECRET"
+
+func TestNewHMACKeyfuncFromEnvOk(t *testing.T) {
+	os.Setenv(sampleEnvVar, sampleSecret)
+	defer os.Unsetenv(sampleEnvVar)
+
+	keyfunc, err := jaywt.NewHMACKeyfuncFromEnv(sampleEnvVar, false)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	key, _ := keyfunc(nil)
+	if string(key.([]byte)) != sampleSecret {
+		t.Errorf("Key: Got %s, want %s", key, sampleSecret)
+	}
+}
+
+func TestNewHMACKeyfuncFromEnvBase64(t *testing.T) {
+	os.Setenv(sampleEnvVar, base64.StdEncoding.EncodeToString([]byte(sampleSecret)))
+	defer os.Unsetenv(sampleEnvVar)
+
+	keyfunc, err := jaywt.NewHMACKeyfuncFromEnv(sampleEnvVar, true)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	key, _ := keyfunc(nil)
+	if string(key.([]byte)) != sampleSecret {
+		t.Errorf("Key: Got %s, want %s", key, sampleSecret)
+	}
+}
+
+func TestNewHMACKeyfuncFromEnvEmpty(t *testing.T) {
+	os.Unsetenv(sampleEnvVar)
+
+	_, err := jaywt.NewHMACKeyfuncFromEnv(sampleEnvVar, false)
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestNewHMACKeyfuncFromEnvBadBase64(t *testing.T) {
+	os.Setenv(sampleEnvVar, "not*base64!")
+	defer os.Unsetenv(sampleEnvVar)
+
+	_, err := jaywt.NewHMACKeyfuncFromEnv(sampleEnvVar, true)
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+var sampleSecretsByKID = map[string][]byte{
+	"old": []byte("oldSecret"),
+	"new": []byte(sampleSecret),
+}
+
+func TestNewHMACKeyfuncByKIDOk(t *testing.T) {
+	keyfunc := jaywt.NewHMACKeyfuncByKID(sampleSecretsByKID)
+
+	for kid, secret := range sampleSecretsByKID {
+		key, err := keyfunc(&jwt.Token{Header: map[string]interface{}{"kid": kid}})
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if string(key.([]byte)) != string(secret) {
+			t.Errorf("Key: Got %s, want %s", key, secret)
+		}
+	}
+}
+
+var kidHeaderTableBad = []map[string]interface{}{
+	{},
+	{"kid": ""},
+	{"kid": "unknown"},
+	{"kid": 1234},
+}
+
+func TestNewHMACKeyfuncByKIDBad(t *testing.T) {
+	keyfunc := jaywt.NewHMACKeyfuncByKID(sampleSecretsByKID)
+
+	for _, header := range kidHeaderTableBad {
+		if _, err := keyfunc(&jwt.Token{Header: header}); err == nil {
+			t.Errorf("%v: Error was expected, got nil", header)
+		}
+	}
+}
+
+func TestNewHMACKeyfuncByKIDUnknown(t *testing.T) {
+	keyfunc := jaywt.NewHMACKeyfuncByKID(sampleSecretsByKID)
+
+	_, err := keyfunc(&jwt.Token{Header: map[string]interface{}{"kid": "unknown"}})
+	if !errors.Is(err, jaywt.ErrUnknownKID) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrUnknownKID)
+	}
+}
+
+func TestNewHMACKeyfuncProviderOk(t *testing.T) {
+	calls := 0
+	keyfunc := jaywt.NewHMACKeyfuncProvider(func() ([]byte, error) {
+		calls++
+		return []byte(sampleSecret), nil
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: keyfunc,
+	})
+
+	for i := 0; i < 3; i++ {
+		req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+		if _, err := p.Get(req); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("Provider calls: Got %d, want 1", calls)
+	}
+}
+
+func TestNewHMACKeyfuncProviderError(t *testing.T) {
+	calls := 0
+	keyfunc := jaywt.NewHMACKeyfuncProvider(func() ([]byte, error) {
+		calls++
+		return nil, errors.New("Vault is sealed")
+	})
+
+	for i := 0; i < 2; i++ {
+		if _, err := keyfunc(&jwt.Token{}); err == nil {
+			t.Error("Error was expected, got nil")
+		}
+	}
+
+	if calls != 2 {
+		t.Errorf("Provider calls: Got %d, want 2", calls)
+	}
+}
+
+func TestSetHMACSecret(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{})
+	p.SetHMACSecret([]byte("oldSecret"))
+	old := secretRequest(t, "oldSecret")
+
+	if _, err := p.Get(old); err != nil {
+		t.Error(err)
+	}
+
+	p.SetHMACSecret([]byte(sampleSecret))
+	if _, err := p.Get(old); err == nil {
+		t.Error("Old secret should be rejected without overlap")
+	}
+
+	if _, err := p.Get(secretRequest(t, sampleSecret)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestSetHMACSecretOverlap(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:     badKeyfunc,
+		HMACOverlap: 1 * time.Hour,
+	})
+	p.SetHMACSecret([]byte("oldSecret"))
+	p.SetHMACSecret([]byte(sampleSecret))
+
+	for _, secret := range []string{"oldSecret", sampleSecret} {
+		if _, err := p.Get(secretRequest(t, secret)); err != nil {
+			t.Errorf("%s: %v", secret, err)
+		}
+	}
+
+	if _, err := p.Get(secretRequest(t, "someOtherSecret")); err == nil {
+		t.Error("Unknown secret should be rejected")
+	}
+
+	p.SetHMACSecret([]byte("newSecret"))
+	if _, err := p.Get(secretRequest(t, "oldSecret")); err == nil {
+		t.Error("Secret older than the previous one should be rejected")
+	}
+}
+
+func TestSetHMACSecretConcurrent(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		HMACOverlap: 1 * time.Hour,
+	})
+	p.SetHMACSecret([]byte(sampleSecret))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				p.Get(secretRequest(t, sampleSecret))
+			}
+		}()
+	}
+
+	for i := 0; i < 50; i++ {
+		p.SetHMACSecret([]byte(sampleSecret))
+	}
+
+	wg.Wait()
+}
+
+func TestAsymmetricMatrix(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKeyfunc := func(_ *jwt.Token) (interface{}, error) {
+		return &ecKey.PublicKey, nil
+	}
+
+	edPub, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	table := []struct {
+		method  jwt.SigningMethod
+		signKey interface{}
+		keyfunc jwt.Keyfunc
+	}{
+		{jwt.SigningMethodRS256, sampleRSAKey, jaywt.NewRSAKeyfunc(&sampleRSAKey.PublicKey)},
+		{jwt.SigningMethodRS512, sampleRSAKey, jaywt.NewRSAKeyfunc(&sampleRSAKey.PublicKey)},
+		{jwt.SigningMethodPS256, sampleRSAKey, jaywt.NewRSAKeyfunc(&sampleRSAKey.PublicKey)},
+		{jwt.SigningMethodPS384, sampleRSAKey, jaywt.NewRSAKeyfunc(&sampleRSAKey.PublicKey)},
+		{jwt.SigningMethodPS512, sampleRSAKey, jaywt.NewRSAKeyfunc(&sampleRSAKey.PublicKey)},
+		{jwt.SigningMethodES256, ecKey, ecKeyfunc},
+		{jaywt.SigningMethodEdDSA, edKey, jaywt.NewEd25519Keyfunc(edPub)},
+	}
+
+	for _, c := range table {
+		req := sampleRequest(t, c.method, jwt.MapClaims{"sub": sampleSubject}, withKey(c.signKey))
+		p := jaywt.New(&jaywt.Options{
+			Keyfunc:       c.keyfunc,
+			SigningMethod: c.method,
+		})
+
+		if _, err := p.Get(req); err != nil {
+			t.Errorf("%s: %v", c.method.Alg(), err)
+		}
+
+		// The token must not pass for a different algorithm
+		p.Options.SigningMethod = jwt.SigningMethodHS256
+		if _, err = p.Get(req); err == nil {
+			t.Errorf("%s: Error was expected, got nil", c.method.Alg())
+		}
+	}
+}
+
+func TestNewEd25519KeyfuncWrongKey(t *testing.T) {
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherPub, _, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := sampleRequest(t, jaywt.SigningMethodEdDSA, jwt.MapClaims{"sub": sampleSubject}, withKey(edKey))
+
+	keyfuncs := []jwt.Keyfunc{
+		jaywt.NewEd25519Keyfunc(otherPub),
+		jaywt.NewRSAKeyfunc(&sampleRSAKey.PublicKey),
+	}
+
+	for _, keyfunc := range keyfuncs {
+		p := jaywt.New(&jaywt.Options{
+			Keyfunc:       keyfunc,
+			SigningMethod: jaywt.SigningMethodEdDSA,
+		})
+
+		if _, err := p.Get(req); err == nil {
+			t.Error("Error was expected, got nil")
+		}
+	}
+}
+
+func TestMinRSAKeySize(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 1024)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := sampleRequest(t, jwt.SigningMethodRS256, jwt.MapClaims{"sub": sampleSubject}, withKey(key))
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:       jaywt.NewRSAKeyfunc(&key.PublicKey),
+		SigningMethod: jwt.SigningMethodRS256,
+	})
+
+	if _, err = p.Get(req); !errors.Is(err, jaywt.ErrWeakKey) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrWeakKey)
+	}
+
+	p.Options.MinRSAKeySize = 1024
+	if _, err = p.Get(req); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMinECKeySize(t *testing.T) {
+	signKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The key on a smaller curve is rejected before verifying the signature
+	key, err := ecdsa.GenerateKey(elliptic.P224(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	req := sampleRequest(t, jwt.SigningMethodES256, jwt.MapClaims{"sub": sampleSubject}, withKey(signKey))
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: func(_ *jwt.Token) (interface{}, error) {
+			return &key.PublicKey, nil
+		},
+		SigningMethod: jwt.SigningMethodES256,
+	})
+
+	if _, err = p.Get(req); !errors.Is(err, jaywt.ErrWeakKey) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrWeakKey)
+	}
+}
+
+func TestKeyResolver(t *testing.T) {
+	resolver := &sampleResolver{}
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: func(_ *jwt.Token) (interface{}, error) {
+			return nil, errors.New("Keyfunc should not be called")
+		},
+		KeyResolver: resolver,
+	})
+
+	if _, err := p.Get(kidRequest(t, sampleKID)); err != nil {
+		t.Fatal(err)
+	}
+
+	if resolver.kid != sampleKID || resolver.alg != "HS256" {
+		t.Errorf("Got %s and %s, want %s and HS256", resolver.kid, resolver.alg, sampleKID)
+	}
+}
+
+func TestKeyResolverError(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		KeyResolver: &sampleResolver{err: errors.New("KMS is down")},
+	})
+
+	if _, err := p.Get(kidRequest(t, sampleKID)); err == nil || !strings.Contains(err.Error(), "KMS is down") {
+		t.Errorf("Got %v, want it to contain 'KMS is down'", err)
+	}
+}
+
+func TestFallbackKeyfunc(t *testing.T) {
+	old := mustGenerateRSAKey()
+	var fallbacks atomic.Int64
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: jaywt.NewRSAKeyfunc(&sampleRSAKey.PublicKey),
+		FallbackKeyfunc: func(token *jwt.Token) (interface{}, error) {
+			fallbacks.Add(1)
+			return &old.PublicKey, nil
+		},
+		SigningMethod: jwt.SigningMethodRS256,
+	})
+
+	for _, key := range []*rsa.PrivateKey{sampleRSAKey, old} {
+		if _, err := p.Get(sampleRequest(t, jwt.SigningMethodRS256, jwt.MapClaims{"sub": sampleSubject}, withKey(key))); err != nil {
+			t.Error(err)
+		}
+	}
+
+	if n := fallbacks.Load(); n != 1 {
+		t.Errorf("Got %d fallbacks, want 1", n)
+	}
+}
+
+func TestFallbackKeyfuncLeeway(t *testing.T) {
+	old := mustGenerateRSAKey()
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: jaywt.NewRSAKeyfunc(&sampleRSAKey.PublicKey),
+		FallbackKeyfunc: func(token *jwt.Token) (interface{}, error) {
+			return &old.PublicKey, nil
+		},
+		SigningMethod: jwt.SigningMethodRS256,
+		Leeway:        time.Minute,
+	})
+
+	claims := jwt.MapClaims{"sub": sampleSubject, "exp": time.Now().Add(-10 * time.Second).Unix()}
+	for _, key := range []*rsa.PrivateKey{sampleRSAKey, old} {
+		if _, err := p.Get(sampleRequest(t, jwt.SigningMethodRS256, claims, withKey(key))); err != nil {
+			t.Error(err)
+		}
+	}
+
+	claims["exp"] = time.Now().Add(-time.Hour).Unix()
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodRS256, claims, withKey(old))); !errors.Is(err, jaywt.ErrTokenExpired) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrTokenExpired)
+	}
+}
+
+func TestFallbackKeyfuncBothFail(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: jaywt.NewRSAKeyfunc(&sampleRSAKey.PublicKey),
+		FallbackKeyfunc: func(token *jwt.Token) (interface{}, error) {
+			return nil, errors.New("Fallback error")
+		},
+		SigningMethod: jwt.SigningMethodRS256,
+	})
+
+	_, err := p.Get(sampleRequest(t, jwt.SigningMethodRS256, jwt.MapClaims{"sub": sampleSubject}, withKey(mustGenerateRSAKey())))
+	if err == nil || strings.Contains(err.Error(), "Fallback error") {
+		t.Errorf("Got %v, want the original error", err)
+	}
+}
+
+func TestFallbackKeyfuncNotRetried(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: jaywt.NewRSAKeyfunc(&sampleRSAKey.PublicKey),
+		FallbackKeyfunc: func(token *jwt.Token) (interface{}, error) {
+			t.Error("FallbackKeyfunc should not be called")
+			return nil, errors.New("Fallback error")
+		},
+		SigningMethod: jwt.SigningMethodRS256,
+	})
+
+	expired := jwt.MapClaims{"sub": sampleSubject, "exp": time.Now().Add(-time.Hour).Unix()}
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodRS256, expired, withKey(sampleRSAKey))); !errors.Is(err, jaywt.ErrTokenExpired) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrTokenExpired)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("Authorization", "Bearer not.a.jwt")
+	if _, err := p.Get(req); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+// Helper functions
+// ---
+
+func secretRequest(t *testing.T, secret string) *http.Request {
+	return sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject}, withKey([]byte(secret)))
+}
+
+// sampleResolver resolves sampleSecret, remembering the headers.
+type sampleResolver struct {
+	kid, alg string
+	err      error
+}
+
+func (r *sampleResolver) Resolve(_ context.Context, kid, alg string) (interface{}, error) {
+	r.kid, r.alg = kid, alg
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	return []byte(sampleSecret), nil
+}
@@ -0,0 +1,20 @@
+package jaywt
+
+import (
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+)
+
+// GetWithKID extracts and validates the JWT token from the request, like Get.
+// It returns the parsed token and the 'kid' header it was verified with, or
+// "" if it has none, if successful. With Options.TrackStats on, the usage of
+// each key ID is also counted in Stats.KeyIDs.
+func (m *Core) GetWithKID(r *http.Request) (*jwt.Token, string, error) {
+	token, err := m.Get(r)
+	if err != nil {
+		return nil, "", err
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	return token, kid, nil
+}
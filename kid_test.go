@@ -0,0 +1,66 @@
+package jaywt_test
+
+import (
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGetWithKID(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	token, kid, err := p.GetWithKID(kidRequest(t, "key-old"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if kid != "key-old" || token.Header["kid"] != kid {
+		t.Errorf("Got %s, want key-old", kid)
+	}
+
+	if _, kid, err = p.GetWithKID(sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{})); err != nil || kid != "" {
+		t.Errorf("Got %q and %v, want no kid", kid, err)
+	}
+}
+
+func TestGetWithKIDInvalid(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	if _, kid, err := p.GetWithKID(httptest.NewRequest(http.MethodGet, "/", nil)); err == nil || kid != "" {
+		t.Errorf("Got %q and %v, want an error", kid, err)
+	}
+}
+
+func TestStatsKeyIDs(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:    sampleKeyfunc,
+		TrackStats: true,
+	})
+
+	for _, kid := range []string{"key-old", "key-new", "key-new"} {
+		if _, _, err := p.GetWithKID(kidRequest(t, kid)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Tokens without a kid aren't counted
+	p.Get(sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{}))
+
+	kids := p.Stats().KeyIDs
+	if len(kids) != 2 || kids["key-old"] != 1 || kids["key-new"] != 2 {
+		t.Errorf("Got %v, want 1 key-old and 2 key-new", kids)
+	}
+}
+
+// Helper functions
+// ---
+
+func kidRequest(t *testing.T, kid string) *http.Request {
+	return sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject}, withHeader("kid", kid))
+}
@@ -0,0 +1,33 @@
+package jaywt
+
+import (
+	"context"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"log/slog"
+)
+
+// Attribute keys of the logs written to Options.Logger.
+const (
+	logKeyKID    = "kid"
+	logKeyAlg    = "alg"
+	logKeyError  = "error"
+	logKeySource = "source"
+)
+
+// tokenAttrs returns the log attributes describing the token.
+func tokenAttrs(token *jwt.Token) []slog.Attr {
+	kid, _ := token.Header["kid"].(string)
+	alg, _ := token.Header["alg"].(string)
+	return []slog.Attr{
+		slog.String(logKeyKID, kid),
+		slog.String(logKeyAlg, alg),
+	}
+}
+
+// discardHandler is a slog.Handler that drops all records.
+type discardHandler struct{}
+
+func (discardHandler) Enabled(context.Context, slog.Level) bool  { return false }
+func (discardHandler) Handle(context.Context, slog.Record) error { return nil }
+func (h discardHandler) WithAttrs([]slog.Attr) slog.Handler      { return h }
+func (h discardHandler) WithGroup(string) slog.Handler           { return h }
@@ -0,0 +1,96 @@
+package jaywt_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoggerSuccess(t *testing.T) {
+	var buf bytes.Buffer
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject}, withHeader("kid", sampleKID))
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Logger:  slog.New(slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+	})
+
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+		return
+	}
+
+	records := decodeLogs(t, &buf)
+	last := records[len(records)-1]
+	if last["level"] != "DEBUG" || last["kid"] != sampleKID || last["alg"] != "HS256" {
+		t.Errorf("Got %v, want a debug record with kid and alg", last)
+	}
+}
+
+func TestLoggerFailure(t *testing.T) {
+	var buf bytes.Buffer
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Logger:  slog.New(slog.NewJSONHandler(&buf, nil)),
+	})
+
+	if _, err := p.Get(req); err == nil {
+		t.Error("Expected error, got nil")
+		return
+	}
+
+	records := decodeLogs(t, &buf)
+	if len(records) != 1 || records[0]["level"] != "WARN" || records[0]["error"] != "Token not found" {
+		t.Errorf("Got %v, want a single warning with the error", records)
+	}
+}
+
+func TestLoggerLeeway(t *testing.T) {
+	var buf bytes.Buffer
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"exp": time.Now().Add(-30 * time.Second).Unix(),
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Leeway:  1 * time.Minute,
+		Logger:  slog.New(slog.NewJSONHandler(&buf, nil)),
+	})
+
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+		return
+	}
+
+	records := decodeLogs(t, &buf)
+	if len(records) != 1 || records[0]["level"] != "INFO" || records[0]["alg"] != "HS256" {
+		t.Errorf("Got %v, want a single info record about the leeway", records)
+	}
+}
+
+// Helper functions
+// ---
+
+func decodeLogs(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	var records []map[string]interface{}
+	dec := json.NewDecoder(buf)
+	for dec.More() {
+		var record map[string]interface{}
+		if err := dec.Decode(&record); err != nil {
+			t.Fatal(err)
+		}
+
+		records = append(records, record)
+	}
+
+	if len(records) == 0 {
+		t.Fatal("No logs were written")
+	}
+
+	return records
+}
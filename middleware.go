@@ -0,0 +1,86 @@
+package jaywt
+
+import (
+	"context"
+	"encoding/json"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+)
+
+// contextKey is the type of the default key Handler stores tokens under.
+type contextKey struct{}
+
+// Handler returns a middleware that validates the request's token using Get.
+// On success, the token is stored in the request context under
+// Options.ContextKey and the request is passed on to next. Otherwise, it
+// responds using Options.ErrorHandler. Requests matched by Options.Skipper
+// are passed on untouched.
+func (m *Core) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		token, err := m.Get(r)
+		if err != nil {
+			m.Options.ErrorHandler(w, r, err)
+			return
+		}
+
+		m.serve(w, r, next, token)
+	})
+}
+
+// ClaimsHandler returns a handler that validates the request's token using
+// Get and responds with its claims as JSON. It is handy for 'whoami' and
+// debugging endpoints. Failures are handled by Options.ErrorHandler.
+func (m *Core) ClaimsHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, err := m.Get(r)
+		if err != nil {
+			m.Options.ErrorHandler(w, r, err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(token.Claims)
+	}
+}
+
+// FromContext returns the token stored by Handler under the default context
+// key, or nil if there is none.
+func FromContext(ctx context.Context) *jwt.Token {
+	return FromContextKey(ctx, contextKey{})
+}
+
+// FromContextKey returns the token stored by Handler under the supplied
+// context key, or nil if there is none. Use it with a custom
+// Options.ContextKey.
+func FromContextKey(ctx context.Context, key interface{}) *jwt.Token {
+	token, _ := ctx.Value(key).(*jwt.Token)
+	return token
+}
+
+// Helper functions
+// ---
+
+// defaultErrorHandler is the default Options.ErrorHandler.
+func defaultErrorHandler(w http.ResponseWriter, _ *http.Request, err error) {
+	status := http.StatusUnauthorized
+	if IsAuthorizationError(err) {
+		status = http.StatusForbidden
+	}
+
+	http.Error(w, http.StatusText(status), status)
+}
+
+func (m *Core) skip(r *http.Request) bool {
+	return m.Options.Skipper != nil && m.Options.Skipper(r)
+}
+
+// serve passes the request with the token stored in its context to next.
+func (m *Core) serve(w http.ResponseWriter, r *http.Request, next http.Handler, token *jwt.Token) {
+	ctx := context.WithValue(r.Context(), m.Options.ContextKey, token)
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
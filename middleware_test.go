@@ -0,0 +1,237 @@
+package jaywt_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHandlerOk(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	var token *jwt.Token
+	rec := httptest.NewRecorder()
+	p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = jaywt.FromContext(r.Context())
+	})).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Status is %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if token == nil {
+		t.Error("Token should be in the context")
+		return
+	}
+
+	if sub := token.Claims.(jwt.MapClaims)["sub"]; sub != sampleSubject {
+		t.Errorf("Claims subject is %s, want %s", sub, sampleSubject)
+	}
+}
+
+func TestHandlerUnauthorized(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	called := false
+	rec := httptest.NewRecorder()
+	p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Status is %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+
+	if called {
+		t.Error("Next handler should not be called")
+	}
+}
+
+func TestHandlerForbidden(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:  sampleKeyfunc,
+		Audience: "api",
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"aud": "web"})
+	rec := httptest.NewRecorder()
+	p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Next handler should not be called")
+	})).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Status is %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+func TestHandlerForbiddenValidator(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Validators: []jaywt.Validator{
+			jaywt.ValidatorFunc(func(_ *jwt.Token, _ *http.Request) error {
+				return jaywt.Forbidden(errors.New("Not an admin"))
+			}),
+		},
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+	rec := httptest.NewRecorder()
+	p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Next handler should not be called")
+	})).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusForbidden {
+		t.Errorf("Status is %d, want %d", rec.Code, http.StatusForbidden)
+	}
+}
+
+var authorizationErrorTable = []struct {
+	err   error
+	authz bool
+}{
+	{jaywt.ErrInvalidAudience, true},
+	{fmt.Errorf("%w: 'admin'", jaywt.ErrForbiddenAudience), true},
+	{jaywt.ErrInsufficientACR, true},
+	{fmt.Errorf("%w: missing write", jaywt.ErrInsufficientScope), true},
+	{jaywt.Forbidden(errors.New("Not an admin")), true},
+	{fmt.Errorf("Wrapped: %w", jaywt.Forbidden(jaywt.ErrClaimInvalid)), true},
+	{jaywt.ErrTokenExpired, false},
+	{jaywt.ErrInvalidIssuer, false},
+	{errors.New("Token not found"), false},
+	{nil, false},
+}
+
+func TestIsAuthorizationError(t *testing.T) {
+	for _, c := range authorizationErrorTable {
+		if got := jaywt.IsAuthorizationError(c.err); got != c.authz {
+			t.Errorf("%v: Got %t, want %t", c.err, got, c.authz)
+		}
+	}
+
+	if err := jaywt.Forbidden(jaywt.ErrClaimInvalid); !errors.Is(err, jaywt.ErrClaimInvalid) {
+		t.Errorf("Forbidden should wrap %v", jaywt.ErrClaimInvalid)
+	}
+}
+
+type customContextKey string
+
+func TestHandlerContextKey(t *testing.T) {
+	const key = customContextKey("user")
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:    sampleKeyfunc,
+		ContextKey: key,
+	})
+
+	rec := httptest.NewRecorder()
+	p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if jaywt.FromContextKey(r.Context(), key) == nil {
+			t.Error("Token should be under the custom key")
+		}
+
+		if jaywt.FromContext(r.Context()) != nil {
+			t.Error("Token should not be under the default key")
+		}
+	})).ServeHTTP(rec, req)
+}
+
+func TestHandlerSkipper(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/health", nil)
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Skipper: func(r *http.Request) bool {
+			return r.URL.Path == "/health"
+		},
+	})
+
+	called := false
+	rec := httptest.NewRecorder()
+	p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	})).ServeHTTP(rec, req)
+
+	if !called {
+		t.Error("Next handler should be called")
+	}
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Status is %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestHandlerErrorHandler(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			http.Error(w, err.Error(), http.StatusTeapot)
+		},
+	})
+
+	rec := httptest.NewRecorder()
+	p.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("Status is %d, want %d", rec.Code, http.StatusTeapot)
+	}
+}
+
+func TestClaimsHandlerOk(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	rec := httptest.NewRecorder()
+	p.ClaimsHandler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Status is %d, want %d", rec.Code, http.StatusOK)
+	}
+
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Content-Type is %s, want application/json", ct)
+	}
+
+	var claims map[string]interface{}
+	if err := json.NewDecoder(rec.Body).Decode(&claims); err != nil {
+		t.Error(err)
+		return
+	}
+
+	if claims["sub"] != sampleSubject {
+		t.Errorf("Claims subject is %s, want %s", claims["sub"], sampleSubject)
+	}
+}
+
+func TestClaimsHandlerUnauthorized(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	rec := httptest.NewRecorder()
+	p.ClaimsHandler().ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Status is %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
@@ -0,0 +1,40 @@
+package jaywt
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+)
+
+// GetWithMTLSBinding extracts and validates the JWT token from the request,
+// then verifies the token is bound to the client's TLS certificate (RFC 8705).
+// The token's 'cnf.x5t#S256' claim must match the SHA-256 thumbprint of the
+// certificate presented by the client. It returns the parsed token, if
+// successful.
+func (m *Core) GetWithMTLSBinding(r *http.Request) (*jwt.Token, error) {
+	return m.getVerified(r, jwt.MapClaims{}, ValidatorFunc(checkMTLSBinding))
+}
+
+// Helper functions
+// ---
+
+// checkMTLSBinding checks that the token is bound to the request's client
+// certificate.
+func checkMTLSBinding(token *jwt.Token, r *http.Request) error {
+	if r.TLS == nil || len(r.TLS.PeerCertificates) == 0 {
+		return ErrNoClientCert
+	}
+
+	sum := sha256.Sum256(r.TLS.PeerCertificates[0].Raw)
+	thumbprint := base64.RawURLEncoding.EncodeToString(sum[:])
+
+	cnf, _ := token.Claims.(jwt.MapClaims)["cnf"].(map[string]interface{})
+	x5t, _ := cnf["x5t#S256"].(string)
+	if subtle.ConstantTimeCompare([]byte(x5t), []byte(thumbprint)) != 1 {
+		return ErrCertBindingMismatch
+	}
+
+	return nil
+}
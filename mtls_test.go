@@ -0,0 +1,95 @@
+package jaywt_test
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"testing"
+)
+
+func TestGetWithMTLSBindingOk(t *testing.T) {
+	cert := sampleClientCert(t)
+	req := mtlsRequest(t, certThumbprint(cert), cert)
+
+	token, err := newMTLSCore().GetWithMTLSBinding(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if sub := token.Claims.(jwt.MapClaims)["sub"]; sub != sampleSubject {
+		t.Errorf("Got %v, want %s", sub, sampleSubject)
+	}
+}
+
+func TestGetWithMTLSBindingMismatch(t *testing.T) {
+	cert := sampleClientCert(t)
+	other := sampleClientCert(t)
+	req := mtlsRequest(t, certThumbprint(other), cert)
+
+	if _, err := newMTLSCore().GetWithMTLSBinding(req); err != jaywt.ErrCertBindingMismatch {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrCertBindingMismatch)
+	}
+}
+
+func TestGetWithMTLSBindingUnbound(t *testing.T) {
+	cert := sampleClientCert(t)
+	req := mtlsRequest(t, "", cert)
+
+	if _, err := newMTLSCore().GetWithMTLSBinding(req); err != jaywt.ErrCertBindingMismatch {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrCertBindingMismatch)
+	}
+}
+
+func TestGetWithMTLSBindingNoCert(t *testing.T) {
+	cert := sampleClientCert(t)
+	req := mtlsRequest(t, certThumbprint(cert), nil)
+
+	if _, err := newMTLSCore().GetWithMTLSBinding(req); err != jaywt.ErrNoClientCert {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrNoClientCert)
+	}
+
+	req.TLS = nil
+	if _, err := newMTLSCore().GetWithMTLSBinding(req); err != jaywt.ErrNoClientCert {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrNoClientCert)
+	}
+}
+
+// Helper functions
+// ---
+
+func newMTLSCore() *jaywt.Core {
+	return jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+}
+
+func sampleClientCert(t *testing.T) *x509.Certificate {
+	ca, caKey := sampleCA(t)
+	return sampleLeafCert(t, ca, caKey, &sampleRSAKey.PublicKey)
+}
+
+func certThumbprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+// mtlsRequest returns a request over TLS with a token bound to the
+// thumbprint, presenting the certificate if not nil.
+func mtlsRequest(t *testing.T, thumbprint string, cert *x509.Certificate) *http.Request {
+	claims := jwt.MapClaims{"sub": sampleSubject}
+	if thumbprint != "" {
+		claims["cnf"] = map[string]interface{}{"x5t#S256": thumbprint}
+	}
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, claims)
+	req.TLS = &tls.ConnectionState{}
+	if cert != nil {
+		req.TLS.PeerCertificates = []*x509.Certificate{cert}
+	}
+
+	return req
+}
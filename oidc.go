@@ -0,0 +1,371 @@
+package jaywt
+
+import (
+	"context"
+	"crypto"
+	_ "crypto/sha256" // registers the SHA-256 hash
+	_ "crypto/sha512" // registers the SHA-384 and SHA-512 hashes
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"reflect"
+	"strings"
+)
+
+// StandardOIDCClaims are the claims of an OpenID Connect ID token, with the
+// registered JWT claims and the standard ones of OpenID Connect Core 1.0.
+// The 'aud' claim may be a single string or an array of them. Other claims
+// are in Extra.
+type StandardOIDCClaims struct {
+	Subject   string   `json:"sub,omitempty"`
+	Issuer    string   `json:"iss,omitempty"`
+	Audience  []string `json:"aud,omitempty"`
+	ExpiresAt int64    `json:"exp,omitempty"`
+	NotBefore int64    `json:"nbf,omitempty"`
+	IssuedAt  int64    `json:"iat,omitempty"`
+	ID        string   `json:"jti,omitempty"`
+
+	AuthTime        int64    `json:"auth_time,omitempty"`
+	Nonce           string   `json:"nonce,omitempty"`
+	ACR             string   `json:"acr,omitempty"`
+	AMR             []string `json:"amr,omitempty"`
+	AuthorizedParty string   `json:"azp,omitempty"`
+	AtHash          string   `json:"at_hash,omitempty"`
+
+	Name                string `json:"name,omitempty"`
+	GivenName           string `json:"given_name,omitempty"`
+	FamilyName          string `json:"family_name,omitempty"`
+	MiddleName          string `json:"middle_name,omitempty"`
+	Nickname            string `json:"nickname,omitempty"`
+	PreferredUsername   string `json:"preferred_username,omitempty"`
+	Profile             string `json:"profile,omitempty"`
+	Picture             string `json:"picture,omitempty"`
+	Website             string `json:"website,omitempty"`
+	Email               string `json:"email,omitempty"`
+	EmailVerified       bool   `json:"email_verified,omitempty"`
+	Gender              string `json:"gender,omitempty"`
+	Birthdate           string `json:"birthdate,omitempty"`
+	Zoneinfo            string `json:"zoneinfo,omitempty"`
+	Locale              string `json:"locale,omitempty"`
+	PhoneNumber         string `json:"phone_number,omitempty"`
+	PhoneNumberVerified bool   `json:"phone_number_verified,omitempty"`
+	UpdatedAt           int64  `json:"updated_at,omitempty"`
+
+	// The remaining claims, e.g. 'address' or custom ones.
+	Extra map[string]interface{} `json:"-"`
+}
+
+// Valid validates the time based claims 'exp', 'iat' and 'nbf', like
+// jwt.StandardClaims.
+func (c *StandardOIDCClaims) Valid() error {
+	return jwt.StandardClaims{
+		ExpiresAt: c.ExpiresAt,
+		IssuedAt:  c.IssuedAt,
+		NotBefore: c.NotBefore,
+	}.Valid()
+}
+
+// UnmarshalJSON decodes the claims, putting the unknown ones into Extra.
+// The 'email_verified' and 'phone_number_verified' claims may be booleans
+// or the strings "true" and "false", as some issuers send them.
+func (c *StandardOIDCClaims) UnmarshalJSON(data []byte) error {
+	type plain StandardOIDCClaims
+	aux := struct {
+		*plain
+		Audience            interface{} `json:"aud,omitempty"`
+		EmailVerified       interface{} `json:"email_verified,omitempty"`
+		PhoneNumberVerified interface{} `json:"phone_number_verified,omitempty"`
+	}{plain: (*plain)(c)}
+	if err := json.Unmarshal(data, &aux); err != nil {
+		return err
+	}
+
+	var err error
+	if c.EmailVerified, err = verifiedClaim("email_verified", aux.EmailVerified); err != nil {
+		return err
+	}
+
+	if c.PhoneNumberVerified, err = verifiedClaim("phone_number_verified", aux.PhoneNumberVerified); err != nil {
+		return err
+	}
+
+	var extra map[string]interface{}
+	if err := json.Unmarshal(data, &extra); err != nil {
+		return err
+	}
+
+	for _, name := range oidcClaimNames {
+		delete(extra, name)
+	}
+
+	c.Audience = audiences(jwt.MapClaims{"aud": aux.Audience})
+	c.Extra = nil
+	if len(extra) > 0 {
+		c.Extra = extra
+	}
+
+	return nil
+}
+
+// MarshalJSON encodes the claims, including Extra.
+func (c *StandardOIDCClaims) MarshalJSON() ([]byte, error) {
+	type plain StandardOIDCClaims
+	data, err := json.Marshal((*plain)(c))
+	if err != nil || len(c.Extra) == 0 {
+		return data, err
+	}
+
+	claims := make(map[string]interface{}, len(c.Extra))
+	for name, value := range c.Extra {
+		claims[name] = value
+	}
+
+	if err = json.Unmarshal(data, &claims); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(claims)
+}
+
+// GetOIDC extracts and validates the JWT token from the request, decoding its
+// claims as StandardOIDCClaims. It returns the parsed token and its claims,
+// if successful.
+func (m *Core) GetOIDC(r *http.Request) (*jwt.Token, *StandardOIDCClaims, error) {
+	claims := &StandardOIDCClaims{}
+	token, err := m.GetWithClaims(r, claims)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return token, claims, nil
+}
+
+// oidcConfiguration is the part of an OpenID Connect discovery document
+// used to configure the Core.
+type oidcConfiguration struct {
+	Issuer     string   `json:"issuer"`
+	JWKSURI    string   `json:"jwks_uri"`
+	Algorithms []string `json:"id_token_signing_alg_values_supported"`
+}
+
+// NewFromOIDC returns a new Core configured from the issuer's OpenID Connect
+// discovery document at '/.well-known/openid-configuration'. Keys are selected
+// from its 'jwks_uri', the 'iss' claim must be the issuer, and only the
+// supported algorithms are accepted. SigningMethod is RS256 when supported,
+// otherwise the first supported algorithm. The keys are fetched right away,
+// so misconfiguration is caught early, and the remaining options can be set
+// on the Core's Options.
+func NewFromOIDC(ctx context.Context, issuer string) (*Core, error) {
+	config, err := discover(ctx, http.DefaultClient, issuer)
+	if err != nil {
+		return nil, err
+	}
+
+	method, methods := discoveredMethods(config.Algorithms)
+	if method == nil {
+		return nil, errors.New("Discovery document has no supported signing algorithm")
+	}
+
+	m := New(&Options{
+		JWKSURL:       config.JWKSURI,
+		Issuer:        config.Issuer,
+		SigningMethod: method,
+		ValidMethods:  methods,
+	})
+	if err = m.Warm(ctx); err != nil {
+		return nil, err
+	}
+
+	return m, nil
+}
+
+// GetWithAtHash extracts and validates the JWT token from the request, then
+// checks that its 'at_hash' claim matches the supplied access token, as
+// required for OpenID Connect ID tokens. It returns the parsed token,
+// if successful.
+func (m *Core) GetWithAtHash(r *http.Request, accessToken string) (*jwt.Token, error) {
+	return m.getVerified(r, jwt.MapClaims{}, ValidatorFunc(func(token *jwt.Token, _ *http.Request) error {
+		return checkAtHash(token, accessToken)
+	}))
+}
+
+// GetWithNonce extracts and validates the JWT token from the request, then
+// checks that its 'nonce' claim matches the one stored when starting the
+// OpenID Connect login. A missing claim is a mismatch, unless expectedNonce
+// is empty. It returns the parsed token, if successful.
+func (m *Core) GetWithNonce(r *http.Request, expectedNonce string) (*jwt.Token, error) {
+	return m.getVerified(r, jwt.MapClaims{}, ValidatorFunc(func(token *jwt.Token, _ *http.Request) error {
+		return checkNonce(token, expectedNonce)
+	}))
+}
+
+// GetPair extracts and validates two JWT tokens from the request, e.g. an
+// access token and an ID token. The first one is extracted by the configured
+// extractor, the second one by secondExtractor. Both are checked like Get,
+// with the same options, and reported separately. It returns both parsed
+// tokens, if successful.
+func (m *Core) GetPair(r *http.Request, secondExtractor TokenExtractor) (access, id *jwt.Token, err error) {
+	access, err = m.Get(r)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	second := r.WithContext(context.WithValue(r.Context(), extractorKey{}, secondExtractor))
+	if id, err = m.Get(second); err != nil {
+		return nil, nil, fmt.Errorf("Error checking second token: %w", err)
+	}
+
+	return access, id, nil
+}
+
+// Helper functions
+// ---
+
+// extractorKey is the context key of the extractor GetPair extracts the
+// second token with, instead of the configured one.
+type extractorKey struct{}
+
+// oidcClaimNames are the names of the claims of StandardOIDCClaims' fields.
+var oidcClaimNames = func() []string {
+	t := reflect.TypeOf(StandardOIDCClaims{})
+	names := make([]string, 0, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		if name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ","); name != "-" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}()
+
+// discover fetches the issuer's OpenID Connect discovery document, which must
+// name the issuer and its 'jwks_uri'.
+func verifiedClaim(name string, value interface{}) (bool, error) {
+	switch verified := value.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return verified, nil
+	case string:
+		if strings.EqualFold(verified, "true") {
+			return true, nil
+		}
+
+		if strings.EqualFold(verified, "false") {
+			return false, nil
+		}
+	}
+
+	return false, fmt.Errorf("Invalid '%s' claim: %v", name, value)
+}
+
+func discover(ctx context.Context, client *http.Client, issuer string) (*oidcConfiguration, error) {
+	discovery := strings.TrimSuffix(issuer, "/") + "/.well-known/openid-configuration"
+	data, err := fetchURL(ctx, client, discovery)
+	if err != nil {
+		return nil, fmt.Errorf("Error fetching discovery document: %w", err)
+	}
+
+	var config oidcConfiguration
+	if err = json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("Error parsing discovery document: %w", err)
+	}
+
+	if config.Issuer != issuer {
+		return nil, fmt.Errorf("Discovery document issuer '%s' doesn't match '%s'", config.Issuer, issuer)
+	}
+
+	if config.JWKSURI == "" {
+		return nil, errors.New("Discovery document has no 'jwks_uri'")
+	}
+
+	return &config, nil
+}
+
+// discoveredMethods returns the preferred signing method and the names of
+// all the supported ones among the algorithms. The 'none' algorithm is never
+// supported.
+func discoveredMethods(algs []string) (jwt.SigningMethod, []string) {
+	var preferred jwt.SigningMethod
+	var methods []string
+	for _, alg := range algs {
+		method := jwt.GetSigningMethod(alg)
+		if method == nil || alg == "none" {
+			continue
+		}
+
+		if preferred == nil || alg == jwt.SigningMethodRS256.Alg() {
+			preferred = method
+		}
+
+		methods = append(methods, alg)
+	}
+
+	return preferred, methods
+}
+
+// checkAtHash checks that the token's 'at_hash' claim matches the access
+// token.
+func checkAtHash(token *jwt.Token, accessToken string) error {
+	want, err := leftHalfHash(token, accessToken)
+	if err != nil {
+		return err
+	}
+
+	got, _ := token.Claims.(jwt.MapClaims)["at_hash"].(string)
+	if subtle.ConstantTimeCompare([]byte(got), []byte(want)) != 1 {
+		return ErrAtHashMismatch
+	}
+
+	return nil
+}
+
+// checkNonce checks that the token's 'nonce' claim is expectedNonce, unless
+// it is empty.
+func checkNonce(token *jwt.Token, expectedNonce string) error {
+	if expectedNonce == "" {
+		return nil
+	}
+
+	claims, err := claimsMap(token)
+	if err != nil {
+		return err
+	}
+
+	got, _ := claims["nonce"].(string)
+	if subtle.ConstantTimeCompare([]byte(got), []byte(expectedNonce)) != 1 {
+		return ErrNonceMismatch
+	}
+
+	return nil
+}
+
+// leftHalfHash hashes the value with the hash function of the token's
+// algorithm and returns the base64url encoded left half of the digest.
+// EdDSA uses SHA-512, as OpenID Connect specifies for Ed25519.
+func leftHalfHash(token *jwt.Token, value string) (string, error) {
+	alg, _ := token.Header["alg"].(string)
+
+	var hash crypto.Hash
+	switch {
+	case alg == SigningMethodEdDSA.Alg():
+		hash = crypto.SHA512
+	case strings.HasSuffix(alg, "256"):
+		hash = crypto.SHA256
+	case strings.HasSuffix(alg, "384"):
+		hash = crypto.SHA384
+	case strings.HasSuffix(alg, "512"):
+		hash = crypto.SHA512
+	default:
+		return "", fmt.Errorf("No hash function for algorithm %s", alg)
+	}
+
+	hasher := hash.New()
+	hasher.Write([]byte(value))
+	digest := hasher.Sum(nil)
+
+	return jwt.EncodeSegment(digest[:len(digest)/2]), nil
+}
@@ -0,0 +1,385 @@
+package jaywt_test
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const sampleAccessToken = "ya29.someOpaqueAccessToken"
+
+func TestGetWithAtHashOk(t *testing.T) {
+	digest := sha256.Sum256([]byte(sampleAccessToken))
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"at_hash": jwt.EncodeSegment(digest[:16]),
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	_, err := p.GetWithAtHash(req, sampleAccessToken)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetWithAtHashOkHS384(t *testing.T) {
+	digest := sha512.Sum384([]byte(sampleAccessToken))
+	req := sampleRequest(t, jwt.SigningMethodHS384, jwt.MapClaims{
+		"at_hash": jwt.EncodeSegment(digest[:24]),
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:       sampleKeyfunc,
+		SigningMethod: jwt.SigningMethodHS384,
+	})
+
+	_, err := p.GetWithAtHash(req, sampleAccessToken)
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetWithAtHashOkEdDSA(t *testing.T) {
+	pub, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	digest := sha512.Sum512([]byte(sampleAccessToken))
+	req := sampleRequest(t, jaywt.SigningMethodEdDSA, jwt.MapClaims{
+		"at_hash": jwt.EncodeSegment(digest[:32]),
+	}, withKey(key))
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: func(*jwt.Token) (interface{}, error) {
+			return pub, nil
+		},
+		SigningMethod: jaywt.SigningMethodEdDSA,
+	})
+
+	if _, err = p.GetWithAtHash(req, sampleAccessToken); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetWithAtHashMismatch(t *testing.T) {
+	digest := sha256.Sum256([]byte("someOtherToken"))
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"at_hash": jwt.EncodeSegment(digest[:16]),
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	_, err := p.GetWithAtHash(req, sampleAccessToken)
+	if err != jaywt.ErrAtHashMismatch {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrAtHashMismatch)
+	}
+}
+
+func TestGetWithAtHashMissing(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+	})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	_, err := p.GetWithAtHash(req, sampleAccessToken)
+	if err != jaywt.ErrAtHashMismatch {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrAtHashMismatch)
+	}
+}
+
+const sampleNonce = "n-0S6_WzA2Mj"
+
+var nonceTable = []struct {
+	claims jwt.MapClaims
+	nonce  string
+	err    error
+}{
+	{jwt.MapClaims{"nonce": sampleNonce}, sampleNonce, nil},
+	{jwt.MapClaims{"nonce": "someOtherNonce"}, sampleNonce, jaywt.ErrNonceMismatch},
+	{jwt.MapClaims{"sub": sampleSubject}, sampleNonce, jaywt.ErrNonceMismatch},
+	{jwt.MapClaims{"nonce": 1234.0}, sampleNonce, jaywt.ErrNonceMismatch},
+	{jwt.MapClaims{"sub": sampleSubject}, "", nil},
+}
+
+func TestGetWithNonce(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	for _, c := range nonceTable {
+		_, err := p.GetWithNonce(sampleRequest(t, jwt.SigningMethodHS256, c.claims), c.nonce)
+		if err != c.err {
+			t.Errorf("%v: Got %v, want %v", c.claims, err, c.err)
+		}
+	}
+}
+
+func TestGetOIDC(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:  sampleKeyfunc,
+		Audience: "api",
+		ClaimValidators: map[string]func(interface{}) error{
+			"tenant": func(value interface{}) error {
+				if value != "acme" {
+					return errors.New("Unknown tenant")
+				}
+
+				return nil
+			},
+		},
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":            sampleSubject,
+		"aud":            []string{"web", "api"},
+		"exp":            time.Now().Add(1 * time.Hour).Unix(),
+		"email":          "jane@example.com",
+		"email_verified": true,
+		"amr":            []string{"pwd", "otp"},
+		"tenant":         "acme",
+	})
+
+	_, claims, err := p.GetOIDC(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if claims.Subject != sampleSubject || claims.Email != "jane@example.com" || !claims.EmailVerified {
+		t.Errorf("Got %+v, want the token's claims", claims)
+	}
+
+	if len(claims.Audience) != 2 || len(claims.AMR) != 2 {
+		t.Errorf("Got %v and %v, want both values", claims.Audience, claims.AMR)
+	}
+
+	if len(claims.Extra) != 1 || claims.Extra["tenant"] != "acme" {
+		t.Errorf("Got %v, want the tenant claim", claims.Extra)
+	}
+}
+
+func TestGetOIDCVerifiedString(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:  sampleKeyfunc,
+		Audience: "api",
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub":                   sampleSubject,
+		"aud":                   "api",
+		"email_verified":        "true",
+		"phone_number_verified": "false",
+	})
+
+	_, claims, err := p.GetOIDC(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !claims.EmailVerified || claims.PhoneNumberVerified {
+		t.Errorf("Got %v and %v, want true and false", claims.EmailVerified, claims.PhoneNumberVerified)
+	}
+
+	if len(claims.Extra) != 0 {
+		t.Errorf("Got %v, want no extra claims", claims.Extra)
+	}
+}
+
+var oidcTableBad = []jwt.MapClaims{
+	{"sub": sampleSubject, "aud": "api", "exp": time.Now().Add(-1 * time.Hour).Unix()},
+	{"sub": sampleSubject, "aud": "web"},
+	{"sub": sampleSubject, "aud": "api", "email_verified": "yes"},
+	{"sub": sampleSubject, "aud": "api", "phone_number_verified": 1},
+}
+
+func TestGetOIDCBad(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:  sampleKeyfunc,
+		Audience: "api",
+	})
+
+	for _, claims := range oidcTableBad {
+		if _, _, err := p.GetOIDC(sampleRequest(t, jwt.SigningMethodHS256, claims)); err == nil {
+			t.Errorf("%v: Error was expected, got nil", claims)
+		}
+	}
+}
+
+func TestNewFromOIDCOk(t *testing.T) {
+	server := sampleOIDCServer(t, []string{"HS256", "RS256"}, "")
+	defer server.Close()
+
+	p, err := jaywt.NewFromOIDC(context.Background(), server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if alg := p.Options.SigningMethod.Alg(); alg != "RS256" {
+		t.Errorf("SigningMethod == %s, want RS256", alg)
+	}
+
+	claims := jwt.MapClaims{"sub": sampleSubject, "iss": server.URL}
+	req := sampleRequest(t, jwt.SigningMethodRS256, claims, withKey(sampleRSAKey), withHeader("kid", sampleKID))
+	if _, err = p.Get(req); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestNewFromOIDCWrongIssuer(t *testing.T) {
+	server := sampleOIDCServer(t, []string{"RS256"}, "https://evil.example.com")
+	defer server.Close()
+
+	if _, err := jaywt.NewFromOIDC(context.Background(), server.URL); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+func TestNewFromOIDCNoAlgorithms(t *testing.T) {
+	server := sampleOIDCServer(t, []string{"none", "XX999"}, "")
+	defer server.Close()
+
+	if _, err := jaywt.NewFromOIDC(context.Background(), server.URL); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+func TestNewFromOIDCUnreachable(t *testing.T) {
+	server := sampleOIDCServer(t, []string{"RS256"}, "")
+	server.Close()
+
+	if _, err := jaywt.NewFromOIDC(context.Background(), server.URL); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+func TestGetPairOk(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject, "typ": "access"})
+	idToken := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject, "typ": "id"})
+	req.AddCookie(&http.Cookie{Name: "id_token", Value: idToken.Header.Get("Authorization")[len("Bearer "):]})
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	access, id, err := p.GetPair(req, cookieExtractor("id_token"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if typ := access.Claims.(jwt.MapClaims)["typ"]; typ != "access" {
+		t.Errorf("Access token: Got %v, want access", typ)
+	}
+
+	if typ := id.Claims.(jwt.MapClaims)["typ"]; typ != "id" {
+		t.Errorf("ID token: Got %v, want id", typ)
+	}
+}
+
+func TestGetPairTrustedHop(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:           sampleKeyfunc,
+		TrustedSkipHeader: "X-Mesh-Authenticated",
+		TrustedSkipValue:  "meshSecret",
+	})
+
+	// Neither token is verified for requests from the trusted hop
+	req := secretRequest(t, "someOtherSecret")
+	idToken := secretRequest(t, "someOtherSecret")
+	req.AddCookie(&http.Cookie{Name: "id_token", Value: idToken.Header.Get("Authorization")[len("Bearer "):]})
+	req.Header.Set("X-Mesh-Authenticated", "meshSecret")
+
+	if _, _, err := p.GetPair(req, cookieExtractor("id_token")); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestGetPairReported(t *testing.T) {
+	checks := 0
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:     sampleKeyfunc,
+		ReplayStore: jaywt.NewMemoryReplayStore(),
+		OnValidate: func(_ *http.Request, _ *jaywt.Result, _ error) {
+			checks++
+		},
+	})
+	idToken := sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject, "jti": "id-1"})
+
+	// The second token is checked and reported like the first one
+	for i, want := range []int{2, 4} {
+		req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject, "jti": fmt.Sprintf("access-%d", i)})
+		req.AddCookie(&http.Cookie{Name: "id_token", Value: idToken})
+		_, _, err := p.GetPair(req, cookieExtractor("id_token"))
+		if i == 0 && err != nil {
+			t.Fatal(err)
+		}
+
+		if i == 1 && !errors.Is(err, jaywt.ErrTokenReplay) {
+			t.Errorf("Got %v, want %v", err, jaywt.ErrTokenReplay)
+		}
+
+		if checks != want {
+			t.Errorf("Got %d checks, want %d", checks, want)
+		}
+	}
+}
+
+func TestGetPairBad(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+
+	if _, _, err := p.GetPair(req, cookieExtractor("id_token")); err == nil {
+		t.Error("Missing second token: Error was expected, got nil")
+	}
+
+	if _, _, err := p.GetPair(req, badExtractor); err == nil {
+		t.Error("Failing extractor: Error was expected, got nil")
+	}
+
+	req.AddCookie(&http.Cookie{Name: "id_token", Value: headerTokenOk})
+	if _, _, err := p.GetPair(req, cookieExtractor("id_token")); err == nil {
+		t.Error("Invalid second token: Error was expected, got nil")
+	}
+
+	if _, _, err := p.GetPair(httptest.NewRequest(http.MethodGet, "/", nil), cookieExtractor("id_token")); err == nil {
+		t.Error("Missing first token: Error was expected, got nil")
+	}
+}
+
+// Helper functions
+// ---
+
+// sampleOIDCServer serves a discovery document with the algorithms, and the
+// JWKS of sampleRSAKey. If issuer is empty, the server's URL is used.
+func sampleOIDCServer(t *testing.T, algs []string, issuer string) *httptest.Server {
+	jwks := sampleJWKS(t, rsaJWK(sampleKID, &sampleRSAKey.PublicKey))
+	mux := http.NewServeMux()
+	server := httptest.NewServer(mux)
+	if issuer == "" {
+		issuer = server.URL
+	}
+
+	mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, _ *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"issuer":                                issuer,
+			"jwks_uri":                              server.URL + "/jwks",
+			"id_token_signing_alg_values_supported": algs,
+		})
+	})
+	mux.HandleFunc("/jwks", func(w http.ResponseWriter, _ *http.Request) {
+		w.Write(jwks)
+	})
+
+	return server
+}
@@ -0,0 +1,109 @@
+package jaywt
+
+import (
+	"errors"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"time"
+)
+
+// Option sets a field of the Options used by NewFunc. It returns an error
+// if the value is invalid.
+type Option func(o *Options) error
+
+// NewFunc returns a new Core configured by the supplied options. It is an
+// alternative to New that validates each value, and fills in the same
+// defaults for the rest.
+func NewFunc(opts ...Option) (*Core, error) {
+	o := &Options{}
+	for _, opt := range opts {
+		if err := opt(o); err != nil {
+			return nil, err
+		}
+	}
+
+	return New(o), nil
+}
+
+// WithKeyfunc sets Options.Keyfunc.
+func WithKeyfunc(keyfunc jwt.Keyfunc) Option {
+	return func(o *Options) error {
+		if keyfunc == nil {
+			return errors.New("Keyfunc must not be nil")
+		}
+
+		o.Keyfunc = keyfunc
+		return nil
+	}
+}
+
+// WithExtractor sets Options.Extractor.
+func WithExtractor(extractor TokenExtractor) Option {
+	return func(o *Options) error {
+		if extractor == nil {
+			return errors.New("Extractor must not be nil")
+		}
+
+		o.Extractor = extractor
+		return nil
+	}
+}
+
+// WithConfigExtractor sets Options.ConfigExtractor.
+func WithConfigExtractor(extractor ConfigExtractor) Option {
+	return func(o *Options) error {
+		if extractor == nil {
+			return errors.New("ConfigExtractor must not be nil")
+		}
+
+		o.ConfigExtractor = extractor
+		return nil
+	}
+}
+
+// WithSigningMethod sets Options.SigningMethod.
+func WithSigningMethod(method jwt.SigningMethod) Option {
+	return func(o *Options) error {
+		if method == nil {
+			return errors.New("SigningMethod must not be nil")
+		}
+
+		o.SigningMethod = method
+		return nil
+	}
+}
+
+// WithAudience sets Options.Audience.
+func WithAudience(aud string) Option {
+	return func(o *Options) error {
+		if aud == "" {
+			return errors.New("Audience must not be empty")
+		}
+
+		o.Audience = aud
+		return nil
+	}
+}
+
+// WithIssuer sets Options.Issuer.
+func WithIssuer(iss string) Option {
+	return func(o *Options) error {
+		if iss == "" {
+			return errors.New("Issuer must not be empty")
+		}
+
+		o.Issuer = iss
+		return nil
+	}
+}
+
+// WithLeeway sets Options.Leeway.
+func WithLeeway(leeway time.Duration) Option {
+	return func(o *Options) error {
+		if leeway < 0 {
+			return errors.New("Leeway must not be negative")
+		}
+
+		o.Leeway = leeway
+		return nil
+	}
+}
@@ -0,0 +1,75 @@
+package jaywt_test
+
+import (
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestNewFuncDefault(t *testing.T) {
+	j, err := jaywt.NewFunc()
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if j.Options.Extractor == nil {
+		t.Error("Extractor should be 'FromAuthHeader'")
+	}
+
+	inputAlg := j.Options.SigningMethod.Alg()
+	wantAlg := jwt.SigningMethodHS256.Alg()
+	if inputAlg != wantAlg {
+		t.Errorf("SigningMethod == %s, want %s", inputAlg, wantAlg)
+	}
+}
+
+func TestNewFuncCustom(t *testing.T) {
+	j, err := jaywt.NewFunc(
+		jaywt.WithKeyfunc(sampleKeyfunc),
+		jaywt.WithExtractor(func(r *http.Request) (string, error) {
+			return customExtractor, nil
+		}),
+		jaywt.WithSigningMethod(jwt.SigningMethodHS384),
+		jaywt.WithAudience("api"),
+		jaywt.WithIssuer("https://issuer.example.com"),
+		jaywt.WithLeeway(30*time.Second),
+	)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	if res, _ := j.Options.Extractor(nil); res != customExtractor {
+		t.Errorf("Extractor: Got %s, want %s", res, customExtractor)
+	}
+
+	if alg := j.Options.SigningMethod.Alg(); alg != jwt.SigningMethodHS384.Alg() {
+		t.Errorf("SigningMethod == %s, want %s", alg, jwt.SigningMethodHS384.Alg())
+	}
+
+	o := j.Options
+	if o.Audience != "api" || o.Issuer != "https://issuer.example.com" || o.Leeway != 30*time.Second {
+		t.Errorf("Options %+v don't match the supplied values", o)
+	}
+}
+
+var optionTableBad = []jaywt.Option{
+	jaywt.WithKeyfunc(nil),
+	jaywt.WithExtractor(nil),
+	jaywt.WithConfigExtractor(nil),
+	jaywt.WithSigningMethod(nil),
+	jaywt.WithAudience(""),
+	jaywt.WithIssuer(""),
+	jaywt.WithLeeway(-1 * time.Second),
+}
+
+func TestNewFuncBad(t *testing.T) {
+	for i, opt := range optionTableBad {
+		if _, err := jaywt.NewFunc(opt); err == nil {
+			t.Errorf("Option %d: Error was expected, got nil", i)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package jaywt
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ForwardedClientIP returns a function resolving the IP address of the
+// client, for Options.ClientIPFunc. Requests from the trusted proxies, given
+// as IP addresses or CIDR ranges, are attributed to the rightmost untrusted
+// address of their X-Forwarded-For header, since proxies append to it. Other
+// requests use http.Request.RemoteAddr, so clients can't spoof their address.
+// Invalid entries are ignored.
+func ForwardedClientIP(trustedProxies []string) func(r *http.Request) string {
+	var trusted []*net.IPNet
+	for _, proxy := range trustedProxies {
+		if !strings.Contains(proxy, "/") {
+			if strings.Contains(proxy, ":") {
+				proxy += "/128"
+			} else {
+				proxy += "/32"
+			}
+		}
+
+		if _, network, err := net.ParseCIDR(proxy); err == nil {
+			trusted = append(trusted, network)
+		}
+	}
+
+	return func(r *http.Request) string {
+		ip := remoteIP(r)
+		if !isTrustedProxy(trusted, ip) {
+			return ip
+		}
+
+		hops := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+		for i := len(hops) - 1; i >= 0; i-- {
+			hop := strings.TrimSpace(hops[i])
+			if net.ParseIP(hop) == nil {
+				break
+			}
+
+			ip = hop
+			if !isTrustedProxy(trusted, ip) {
+				break
+			}
+		}
+
+		return ip
+	}
+}
+
+// Helper functions
+// ---
+
+// remoteIP returns the host part of the request's RemoteAddr.
+func remoteIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return host
+}
+
+// isTrustedProxy reports whether the IP address is in one of the networks.
+func isTrustedProxy(trusted []*net.IPNet, addr string) bool {
+	ip := net.ParseIP(addr)
+	if ip == nil {
+		return false
+	}
+
+	for _, network := range trusted {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
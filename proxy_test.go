@@ -0,0 +1,50 @@
+package jaywt_test
+
+import (
+	"github.com/oreqizer/go-jaywt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+var forwardedClientIPTable = []struct {
+	remoteAddr string
+	forwarded  []string
+	ip         string
+}{
+	// Untrusted peers can't spoof their address
+	{"203.0.113.7:1234", []string{"198.51.100.1"}, "203.0.113.7"},
+	{"10.0.0.1:1234", nil, "10.0.0.1"},
+	{"10.0.0.1:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+	// The client can prepend made up addresses, only proxies are skipped
+	{"10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1, 192.168.1.5"}, "198.51.100.1"},
+	{"10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1", "192.168.1.5"}, "198.51.100.1"},
+	{"10.0.0.1:1234", []string{"192.168.1.5"}, "192.168.1.5"},
+	{"10.0.0.1:1234", []string{"198.51.100.1, garbage"}, "10.0.0.1"},
+	{"[2001:db8::1]:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+	{"[2001:db8::2]:1234", []string{"198.51.100.1"}, "2001:db8::2"},
+}
+
+func TestForwardedClientIP(t *testing.T) {
+	clientIP := jaywt.ForwardedClientIP([]string{"10.0.0.0/8", "192.168.1.5", "2001:db8::1", "not-an-ip"})
+	for _, c := range forwardedClientIPTable {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.RemoteAddr = c.remoteAddr
+		for _, v := range c.forwarded {
+			req.Header.Add("X-Forwarded-For", v)
+		}
+
+		if ip := clientIP(req); ip != c.ip {
+			t.Errorf("%s %v: Got %s, want %s", c.remoteAddr, c.forwarded, ip, c.ip)
+		}
+	}
+}
+
+func TestForwardedClientIPUntrusted(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Forwarded-For", "198.51.100.1")
+
+	if ip := jaywt.New(&jaywt.Options{}).Options.ClientIPFunc(req); ip != "192.0.2.1" {
+		t.Errorf("Got %s, want 192.0.2.1", ip)
+	}
+}
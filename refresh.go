@@ -0,0 +1,171 @@
+package jaywt
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"time"
+)
+
+// Refresh issues a copy of the token valid for the supplied duration from
+// now, but not past Options.RefreshMaxLifetime since the session started.
+// The copy has the claims the token was issued with, before ClaimsRoot or
+// ClaimTransform rewrote them, and is signed with SigningMethod using
+// SignKey, with SignKeyID as its 'kid'.
+func (m *Core) Refresh(token *jwt.Token, ttl time.Duration) (string, error) {
+	if m.Options.SignKey == nil {
+		return "", errors.New("SignKey is required to refresh tokens")
+	}
+
+	refreshed, err := m.issuedClaims(token)
+	if err != nil {
+		return "", err
+	}
+
+	// Refresh the nested claims, which are the validated ones
+	claims := refreshed
+	if root := m.Options.ClaimsRoot; root != "" {
+		nested, ok := refreshed[root].(map[string]interface{})
+		if !ok {
+			return "", ErrClaimsRootMissing
+		}
+
+		claims = nested
+	}
+
+	now := time.Now()
+	auth := sessionStart(claims, now)
+	deadline := auth.Add(m.Options.RefreshMaxLifetime)
+	if !now.Before(deadline) {
+		return "", ErrRefreshExpired
+	}
+
+	exp := now.Add(ttl)
+	if exp.After(deadline) {
+		exp = deadline
+	}
+
+	claims["auth_time"] = auth.Unix()
+	claims["iat"] = now.Unix()
+	claims["exp"] = exp.Unix()
+	if _, ok := refreshed["exp"]; ok {
+		refreshed["exp"] = exp.Unix()
+	}
+
+	next := jwt.NewWithClaims(m.Options.SigningMethod, refreshed)
+	if kid := m.Options.SignKeyID; kid != "" {
+		next.Header["kid"] = kid
+	}
+
+	signed, err := next.SignedString(m.Options.SignKey)
+	if err != nil {
+		return "", fmt.Errorf("Error signing token: %w", err)
+	}
+
+	return signed, nil
+}
+
+// GetWithRefreshHint works like Get, but also reports whether the token
+// expires within the supplied window and should be refreshed soon. Tokens
+// without the 'exp' claim never need refreshing.
+func (m *Core) GetWithRefreshHint(r *http.Request, window time.Duration) (*jwt.Token, bool, error) {
+	token, err := m.Get(r)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return token, expiresWithin(token, window), nil
+}
+
+// RefreshHandler works like Handler, but also implements sliding sessions.
+// When the token expires sooner than Options.RefreshThreshold, it is refreshed
+// for Options.RefreshTTL and set in the response as Options.RefreshCookie.
+// Only verified tokens are refreshed, not those from a trusted hop or within
+// ExpiredGrace. Failing to refresh doesn't fail the request.
+func (m *Core) RefreshHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if m.skip(r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		res, err := m.check(r, jwt.MapClaims{})
+		if err == nil {
+			_, err = verifiedToken(res)
+		}
+		if err != nil {
+			m.Options.ErrorHandler(w, r, err)
+			return
+		}
+
+		token := res.Token
+		if m.shouldRefresh(res) {
+			if signed, err := m.Refresh(token, m.Options.RefreshTTL); err == nil {
+				cookie := *m.Options.RefreshCookie
+				cookie.Value = signed
+				cookie.Expires = time.Now().Add(m.Options.RefreshTTL)
+				http.SetCookie(w, &cookie)
+			}
+		}
+
+		m.serve(w, r, next, token)
+	})
+}
+
+// Helper functions
+// ---
+
+func (m *Core) shouldRefresh(res *Result) bool {
+	o := m.Options
+	if o.RefreshThreshold <= 0 || o.RefreshTTL <= 0 || o.RefreshCookie == nil {
+		return false
+	}
+
+	if !res.Verified || res.TrustedHop || res.Expired {
+		return false
+	}
+
+	return expiresWithin(res.Token, o.RefreshThreshold)
+}
+
+// issuedClaims returns a copy of the claims the token was issued with,
+// decoded from the raw token, or of its claims if it wasn't parsed.
+func (m *Core) issuedClaims(token *jwt.Token) (jwt.MapClaims, error) {
+	if token.Raw != "" {
+		claims, err := m.decodeClaims(token.Raw)
+		return jwt.MapClaims(claims), err
+	}
+
+	data, err := json.Marshal(token.Claims)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading claims: %w", err)
+	}
+
+	var claims jwt.MapClaims
+	if err = m.Options.JSONUnmarshal(data, &claims); err != nil {
+		return nil, fmt.Errorf("Error reading claims: %w", err)
+	}
+
+	return claims, nil
+}
+
+// sessionStart returns when the session of the claims started, by their
+// 'auth_time' or 'iat' claim, or now if they have neither.
+func sessionStart(claims jwt.MapClaims, now time.Time) time.Time {
+	if auth, ok := numericClaim(claims, "auth_time"); ok {
+		return time.Unix(auth, 0)
+	}
+
+	if iat, ok := numericClaim(claims, "iat"); ok {
+		return time.Unix(iat, 0)
+	}
+
+	return now
+}
+
+func expiresWithin(token *jwt.Token, window time.Duration) bool {
+	exp, ok := expiresAt(token)
+	return ok && time.Until(exp) < window
+}
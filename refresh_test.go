@@ -0,0 +1,320 @@
+package jaywt_test
+
+import (
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+const sampleCookieName = "session"
+
+func TestRefreshOk(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		SignKey: []byte(sampleSecret),
+	})
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+		"exp": time.Now().Add(1 * time.Minute).Unix(),
+	})
+
+	signed, err := p.Refresh(token, 1*time.Hour)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	parsed, err := jwt.Parse(signed, sampleKeyfunc)
+	if err != nil {
+		t.Error(err)
+		return
+	}
+
+	claims := parsed.Claims.(jwt.MapClaims)
+	if claims["sub"] != sampleSubject {
+		t.Errorf("Claims subject is %s, want %s", claims["sub"], sampleSubject)
+	}
+
+	if exp := int64(claims["exp"].(float64)); exp < time.Now().Add(59*time.Minute).Unix() {
+		t.Errorf("Claims exp is %d, want about an hour from now", exp)
+	}
+}
+
+func TestRefreshNoSignKey(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{})
+
+	_, err := p.Refresh(token, 1*time.Hour)
+	if err == nil {
+		t.Error("Expected error, got nil")
+	}
+}
+
+func TestRefreshClaimsRoot(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:    sampleKeyfunc,
+		SignKey:    []byte(sampleSecret),
+		ClaimsRoot: "data",
+		ClaimTransform: func(claims jwt.MapClaims) jwt.MapClaims {
+			return jwt.MapClaims{"renamed": claims["sub"]}
+		},
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"data": map[string]interface{}{
+			"sub": sampleSubject,
+			"exp": time.Now().Add(1 * time.Minute).Unix(),
+		},
+	})
+
+	token, err := p.Get(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := p.Refresh(token, 1*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The refreshed token has the claims as issued, so it passes again
+	refreshed, err := p.ValidateRaw(signed)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := refreshed.Claims.(jwt.MapClaims)["renamed"]; got != sampleSubject {
+		t.Errorf("Got %v, want %v", got, sampleSubject)
+	}
+}
+
+func TestRefreshSignKeyID(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:   sampleKeyfunc,
+		SignKey:   []byte(sampleSecret),
+		SignKeyID: "signing",
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject}, withHeader("kid", "incoming"))
+
+	token, err := p.Get(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signed, err := p.Refresh(token, 1*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	parsed, err := jwt.Parse(signed, sampleKeyfunc)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if kid := parsed.Header["kid"]; kid != "signing" {
+		t.Errorf("Got %v, want %v", kid, "signing")
+	}
+}
+
+var refreshMaxLifetimeTable = []struct {
+	started time.Duration
+	exp     time.Duration
+	err     error
+}{
+	{-1 * time.Hour, 1 * time.Hour, nil},
+	{-23*time.Hour - 30*time.Minute, 30 * time.Minute, nil},
+	{-25 * time.Hour, 0, jaywt.ErrRefreshExpired},
+}
+
+func TestRefreshMaxLifetime(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		SignKey: []byte(sampleSecret),
+	})
+
+	for _, c := range refreshMaxLifetimeTable {
+		started := time.Now().Add(c.started).Unix()
+		token := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"auth_time": started})
+		signed, err := p.Refresh(token, 1*time.Hour)
+		if err != c.err {
+			t.Errorf("Got %v, want %v", err, c.err)
+			continue
+		}
+
+		if err != nil {
+			continue
+		}
+
+		parsed, err := jwt.Parse(signed, sampleKeyfunc)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		claims := parsed.Claims.(jwt.MapClaims)
+		if auth := int64(claims["auth_time"].(float64)); auth != started {
+			t.Errorf("Got auth_time %d, want %d", auth, started)
+		}
+
+		want := time.Now().Add(c.exp).Unix()
+		if exp := int64(claims["exp"].(float64)); exp < want-1 || exp > want+1 {
+			t.Errorf("Got exp %d, want %d", exp, want)
+		}
+	}
+}
+
+var refreshHintTable = []struct {
+	claims jwt.MapClaims
+	hint   bool
+}{
+	{jwt.MapClaims{"sub": sampleSubject, "exp": time.Now().Add(1 * time.Minute).Unix()}, true},
+	{jwt.MapClaims{"sub": sampleSubject, "exp": time.Now().Add(1 * time.Hour).Unix()}, false},
+	{jwt.MapClaims{"sub": sampleSubject}, false},
+}
+
+func TestGetWithRefreshHint(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	for _, c := range refreshHintTable {
+		req := sampleRequest(t, jwt.SigningMethodHS256, c.claims)
+		token, hint, err := p.GetWithRefreshHint(req, 5*time.Minute)
+		if err != nil {
+			t.Error(err)
+			continue
+		}
+
+		if token == nil {
+			t.Error("Token should not be nil")
+		}
+
+		if hint != c.hint {
+			t.Errorf("Got hint %t, want %t for %v", hint, c.hint, c.claims)
+		}
+	}
+}
+
+func TestGetWithRefreshHintInvalid(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: badKeyfunc,
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+
+	if _, hint, err := p.GetWithRefreshHint(req, 5*time.Minute); err == nil || hint {
+		t.Errorf("Got hint %t and error %v, want false and an error", hint, err)
+	}
+}
+
+func TestRefreshHandlerRefreshes(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+		"exp": time.Now().Add(1 * time.Minute).Unix(),
+	})
+	p := newRefreshCore()
+
+	rec := httptest.NewRecorder()
+	p.RefreshHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if jaywt.FromContext(r.Context()) == nil {
+			t.Error("Token should be in the context")
+		}
+	})).ServeHTTP(rec, req)
+
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 {
+		t.Errorf("Got %d cookies, want 1", len(cookies))
+		return
+	}
+
+	if cookies[0].Name != sampleCookieName || !cookies[0].HttpOnly {
+		t.Errorf("Cookie %v doesn't match the template", cookies[0])
+	}
+
+	if _, err := jwt.Parse(cookies[0].Value, sampleKeyfunc); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestRefreshHandlerFresh(t *testing.T) {
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	})
+	p := newRefreshCore()
+
+	rec := httptest.NewRecorder()
+	p.RefreshHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(rec, req)
+
+	if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+		t.Errorf("Got %d cookies, want 0", len(cookies))
+	}
+}
+
+func TestRefreshHandlerUnverified(t *testing.T) {
+	p := newRefreshCore()
+	p.Options.TrustedSkipHeader = "X-Mesh-Authenticated"
+	p.Options.TrustedSkipValue = "meshSecret"
+	p.Options.ExpiredGrace = 1 * time.Hour
+
+	// Neither tokens from the trusted hop nor expired ones are refreshed
+	hop := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+		"exp": time.Now().Add(1 * time.Minute).Unix(),
+	}, withKey([]byte("someOtherSecret")))
+	hop.Header.Set("X-Mesh-Authenticated", "meshSecret")
+	expired := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"sub": sampleSubject,
+		"exp": time.Now().Add(-1 * time.Minute).Unix(),
+	})
+
+	for _, req := range []*http.Request{hop, expired} {
+		called := false
+		rec := httptest.NewRecorder()
+		p.RefreshHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+		})).ServeHTTP(rec, req)
+
+		if !called {
+			t.Error("Next handler should be called")
+		}
+
+		if cookies := rec.Result().Cookies(); len(cookies) != 0 {
+			t.Errorf("Got %d cookies, want 0", len(cookies))
+		}
+	}
+}
+
+func TestRefreshHandlerUnauthorized(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	p := newRefreshCore()
+
+	rec := httptest.NewRecorder()
+	p.RefreshHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Error("Next handler should not be called")
+	})).ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Status is %d, want %d", rec.Code, http.StatusUnauthorized)
+	}
+}
+
+// Helper functions
+// ---
+
+func newRefreshCore() *jaywt.Core {
+	return jaywt.New(&jaywt.Options{
+		Keyfunc:          sampleKeyfunc,
+		SignKey:          []byte(sampleSecret),
+		RefreshThreshold: 5 * time.Minute,
+		RefreshTTL:       1 * time.Hour,
+		RefreshCookie: &http.Cookie{
+			Name:     sampleCookieName,
+			Path:     "/",
+			HttpOnly: true,
+		},
+	})
+}
@@ -0,0 +1,51 @@
+package jaywt
+
+import (
+	"sync"
+	"time"
+)
+
+// ReplayStore remembers the 'jti' claims of consumed tokens, for single-use
+// tokens such as magic links. Implementations must be safe for concurrent use.
+type ReplayStore interface {
+	// CheckAndMark reports whether the jti was seen before, and marks it as
+	// seen. The jti needs to be remembered until exp, after which the token
+	// is rejected anyway. It is the token's 'exp' claim extended by
+	// Options.Leeway and Options.ExpiredGrace. A zero exp means the token
+	// doesn't expire.
+	CheckAndMark(jti string, exp time.Time) (seen bool, err error)
+}
+
+// NewMemoryReplayStore returns a ReplayStore keeping the seen 'jti' claims
+// in memory, until the tokens expire. It only works for a single instance;
+// share a store between instances otherwise.
+func NewMemoryReplayStore() ReplayStore {
+	return &memoryReplayStore{seen: make(map[string]time.Time)}
+}
+
+// Helper functions
+// ---
+
+type memoryReplayStore struct {
+	mu   sync.Mutex
+	seen map[string]time.Time
+}
+
+func (s *memoryReplayStore) CheckAndMark(jti string, exp time.Time) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, e := range s.seen {
+		if !e.IsZero() && now.After(e) {
+			delete(s.seen, k)
+		}
+	}
+
+	if _, ok := s.seen[jti]; ok {
+		return true, nil
+	}
+
+	s.seen[jti] = exp
+	return false, nil
+}
@@ -0,0 +1,104 @@
+package jaywt_test
+
+import (
+	"errors"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"testing"
+	"time"
+)
+
+func TestReplayStore(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:     sampleKeyfunc,
+		ReplayStore: jaywt.NewMemoryReplayStore(),
+	})
+	claims := jwt.MapClaims{
+		"jti": "link-1",
+		"exp": time.Now().Add(1 * time.Hour).Unix(),
+	}
+
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, claims)); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, claims)); err != jaywt.ErrTokenReplay {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrTokenReplay)
+	}
+
+	claims["jti"] = "link-2"
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, claims)); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestReplayStoreNoJTI(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:     sampleKeyfunc,
+		ReplayStore: jaywt.NewMemoryReplayStore(),
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+
+	if _, err := p.Get(req); err != jaywt.ErrTokenReplay {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrTokenReplay)
+	}
+}
+
+func TestReplayStoreError(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:     sampleKeyfunc,
+		ReplayStore: badReplayStore{},
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"jti": "link-1"})
+
+	if _, err := p.Get(req); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+func TestReplayStoreExpiredGrace(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:      sampleKeyfunc,
+		ReplayStore:  jaywt.NewMemoryReplayStore(),
+		Leeway:       1 * time.Minute,
+		ExpiredGrace: 1 * time.Hour,
+	})
+	claims := jwt.MapClaims{
+		"jti": "link-1",
+		"exp": time.Now().Add(-5 * time.Minute).Unix(),
+	}
+
+	// The jti outlives 'exp' while the token is still accepted
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, claims)); err != nil {
+		t.Error(err)
+	}
+
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, claims)); err != jaywt.ErrTokenReplay {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrTokenReplay)
+	}
+}
+
+func TestMemoryReplayStoreEviction(t *testing.T) {
+	store := jaywt.NewMemoryReplayStore()
+
+	if seen, _ := store.CheckAndMark("link-1", time.Now().Add(-1*time.Second)); seen {
+		t.Error("New jti should not be seen")
+	}
+
+	if seen, _ := store.CheckAndMark("link-1", time.Now().Add(1*time.Hour)); seen {
+		t.Error("Expired jti should be evicted")
+	}
+
+	if seen, _ := store.CheckAndMark("link-1", time.Now().Add(1*time.Hour)); !seen {
+		t.Error("Marked jti should be seen")
+	}
+}
+
+// Helper functions
+// ---
+
+type badReplayStore struct{}
+
+func (badReplayStore) CheckAndMark(_ string, _ time.Time) (bool, error) {
+	return false, errors.New("Store is down")
+}
@@ -0,0 +1,86 @@
+package jaywt
+
+import (
+	"fmt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"strings"
+)
+
+// Scopes returns the scopes in the token's 'scope' claim, split by
+// Options.ScopeSeparator. Surrounding spaces and empty scopes are dropped, so
+// "read, write" splits fine with the "," separator. Tokens without the claim
+// have no scopes.
+func (m *Core) Scopes(token *jwt.Token) []string {
+	claims, err := claimsMap(token)
+	if err != nil {
+		return nil
+	}
+
+	scope, _ := claims["scope"].(string)
+	var scopes []string
+	for _, s := range strings.Split(scope, m.Options.ScopeSeparator) {
+		if s = strings.TrimSpace(s); s != "" {
+			scopes = append(scopes, s)
+		}
+	}
+
+	return scopes
+}
+
+// HasScope reports whether the token's 'scope' claim contains the scope.
+func (m *Core) HasScope(token *jwt.Token, scope string) bool {
+	return containsString(m.Scopes(token), scope)
+}
+
+// GetWithScopes extracts and validates the JWT token from the request, then
+// checks that its 'scope' claim contains all the required scopes. Otherwise,
+// it fails with ErrInsufficientScope naming the missing ones. It returns the
+// parsed token, if successful.
+func (m *Core) GetWithScopes(r *http.Request, required ...string) (*jwt.Token, error) {
+	return m.getVerified(r, jwt.MapClaims{}, ValidatorFunc(func(token *jwt.Token, _ *http.Request) error {
+		return m.checkScopes(token, required)
+	}))
+}
+
+// GetWithAnyScope extracts and validates the JWT token from the request, then
+// checks that its 'scope' claim contains at least one of the scopes, so it
+// always fails without any. Otherwise, it fails with ErrInsufficientScope.
+// It returns the parsed token, if successful.
+func (m *Core) GetWithAnyScope(r *http.Request, scopes ...string) (*jwt.Token, error) {
+	return m.getVerified(r, jwt.MapClaims{}, ValidatorFunc(func(token *jwt.Token, _ *http.Request) error {
+		return m.checkAnyScope(token, scopes)
+	}))
+}
+
+// Helper functions
+// ---
+
+// checkScopes checks that the token has all the required scopes.
+func (m *Core) checkScopes(token *jwt.Token, required []string) error {
+	scopes := m.Scopes(token)
+	var missing []string
+	for _, scope := range required {
+		if !containsString(scopes, scope) {
+			missing = append(missing, scope)
+		}
+	}
+
+	if len(missing) > 0 {
+		return fmt.Errorf("%w: missing %s", ErrInsufficientScope, strings.Join(missing, ", "))
+	}
+
+	return nil
+}
+
+// checkAnyScope checks that the token has at least one of the scopes.
+func (m *Core) checkAnyScope(token *jwt.Token, scopes []string) error {
+	have := m.Scopes(token)
+	for _, scope := range scopes {
+		if containsString(have, scope) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w: needs one of %s", ErrInsufficientScope, strings.Join(scopes, ", "))
+}
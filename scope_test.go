@@ -0,0 +1,104 @@
+package jaywt_test
+
+import (
+	"errors"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var scopesTable = []struct {
+	separator string
+	scope     interface{}
+	want      []string
+}{
+	{"", "read write", []string{"read", "write"}},
+	{" ", "read  write ", []string{"read", "write"}},
+	{",", "read,write", []string{"read", "write"}},
+	{",", "read, write", []string{"read", "write"}},
+	{",", "read write", []string{"read write"}},
+	{"", "", nil},
+	{"", nil, nil},
+	{"", 1234, nil},
+}
+
+func TestScopes(t *testing.T) {
+	for _, c := range scopesTable {
+		p := jaywt.New(&jaywt.Options{
+			ScopeSeparator: c.separator,
+		})
+		claims := jwt.MapClaims{}
+		if c.scope != nil {
+			claims["scope"] = c.scope
+		}
+
+		got := p.Scopes(&jwt.Token{Claims: claims})
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%q split by %q: Got %q, want %q", c.scope, c.separator, got, c.want)
+		}
+	}
+}
+
+func TestHasScope(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		ScopeSeparator: ",",
+	})
+	token := &jwt.Token{Claims: jwt.MapClaims{"scope": "read,write"}}
+
+	if !p.HasScope(token, "write") {
+		t.Error("Token should have the 'write' scope")
+	}
+
+	if p.HasScope(token, "admin") {
+		t.Error("Token should not have the 'admin' scope")
+	}
+}
+
+var scopesRequiredTable = []struct {
+	required []string
+	all      bool
+	any      bool
+}{
+	{[]string{"read"}, true, true},
+	{[]string{"read", "write"}, true, true},
+	{[]string{"read", "admin"}, false, true},
+	{[]string{"admin", "delete"}, false, false},
+	{nil, true, false},
+}
+
+func TestGetWithScopes(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"scope": "read write"})
+
+	for _, c := range scopesRequiredTable {
+		_, err := p.GetWithScopes(req, c.required...)
+		if c.all && err != nil || !c.all && !errors.Is(err, jaywt.ErrInsufficientScope) {
+			t.Errorf("All of %v: Got %v, want %t", c.required, err, c.all)
+		}
+
+		_, err = p.GetWithAnyScope(req, c.required...)
+		if c.any && err != nil || !c.any && !errors.Is(err, jaywt.ErrInsufficientScope) {
+			t.Errorf("Any of %v: Got %v, want %t", c.required, err, c.any)
+		}
+	}
+}
+
+func TestGetWithScopesMissing(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"scope": "read"})
+
+	_, err := p.GetWithScopes(req, "read", "write", "admin")
+	if err == nil || !strings.HasSuffix(err.Error(), "missing write, admin") {
+		t.Errorf("Got %v, want the missing scopes", err)
+	}
+
+	if !jaywt.IsAuthorizationError(err) {
+		t.Errorf("%v should be an authorization error", err)
+	}
+}
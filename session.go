@@ -0,0 +1,36 @@
+package jaywt
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+)
+
+// Helper functions
+// ---
+
+// touchSession passes the verified token's subject and ID to
+// Options.SessionTouch. Its errors only fail the check if
+// FailOnSessionTouchError is on, and are logged otherwise.
+func (m *Core) touchSession(ctx context.Context, res *Result) error {
+	if !res.Verified {
+		return nil
+	}
+
+	claims, err := claimsMap(res.Token)
+	if err != nil {
+		return err
+	}
+
+	sub, _ := claims["sub"].(string)
+	jti, _ := claims["jti"].(string)
+	if err = m.Options.SessionTouch(ctx, sub, jti); err != nil {
+		if m.Options.FailOnSessionTouchError {
+			return fmt.Errorf("Error touching session: %w", err)
+		}
+
+		m.Options.Logger.LogAttrs(ctx, slog.LevelWarn, "Session touch failed", slog.Any(logKeyError, err))
+	}
+
+	return nil
+}
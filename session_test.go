@@ -0,0 +1,54 @@
+package jaywt_test
+
+import (
+	"context"
+	"errors"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestSessionTouch(t *testing.T) {
+	var touched []string
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		SessionTouch: func(_ context.Context, sub, jti string) error {
+			touched = append(touched, sub+" "+jti)
+			return nil
+		},
+	})
+
+	if _, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject, "jti": "session-1"})); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := p.Get(httptest.NewRequest(http.MethodGet, "/", nil)); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+
+	if len(touched) != 1 || touched[0] != sampleSubject+" session-1" {
+		t.Errorf("Got %v, want one touch of the session", touched)
+	}
+}
+
+func TestSessionTouchError(t *testing.T) {
+	errSession := errors.New("Session store is down")
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		SessionTouch: func(_ context.Context, _, _ string) error {
+			return errSession
+		},
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+	}
+
+	p.Options.FailOnSessionTouchError = true
+	if _, err := p.Get(req); !errors.Is(err, errSession) {
+		t.Errorf("Got %v, want %v", err, errSession)
+	}
+}
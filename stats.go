@@ -0,0 +1,115 @@
+package jaywt
+
+import (
+	"errors"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"sync/atomic"
+)
+
+// Stats counts the outcomes of the checks made by Get and the other checking
+// functions, if Options.TrackStats is on.
+type Stats struct {
+	// Accepted tokens.
+	Successes int64
+	// Requests without a token, including ErrNoCredentials.
+	Missing int64
+	// Tokens that aren't JWTs.
+	Malformed int64
+	// Tokens with an invalid signature.
+	InvalidSignature int64
+	// Expired tokens, see ErrTokenExpired.
+	Expired int64
+	// Tokens for another audience, see ErrInvalidAudience.
+	InvalidAudience int64
+	// Tokens from another issuer, see ErrInvalidIssuer.
+	InvalidIssuer int64
+	// Other failures.
+	Other int64
+	// Accepted tokens with a verified signature by their 'kid' header, e.g.
+	// to watch the usage of a key decay before retiring it. Nil if there are
+	// none.
+	KeyIDs map[string]int64
+}
+
+// Stats returns the counts of the outcomes of checks so far. They are all
+// zero unless Options.TrackStats is on.
+func (m *Core) Stats() Stats {
+	stats := Stats{
+		Successes:        m.stats[statsSuccess].Load(),
+		Missing:          m.stats[statsMissing].Load(),
+		Malformed:        m.stats[statsMalformed].Load(),
+		InvalidSignature: m.stats[statsInvalidSignature].Load(),
+		Expired:          m.stats[statsExpired].Load(),
+		InvalidAudience:  m.stats[statsInvalidAudience].Load(),
+		InvalidIssuer:    m.stats[statsInvalidIssuer].Load(),
+		Other:            m.stats[statsOther].Load(),
+	}
+
+	m.kids.Range(func(kid, count interface{}) bool {
+		if stats.KeyIDs == nil {
+			stats.KeyIDs = make(map[string]int64)
+		}
+
+		stats.KeyIDs[kid.(string)] = count.(*atomic.Int64).Load()
+		return true
+	})
+
+	return stats
+}
+
+// Helper functions
+// ---
+
+// Categories of Stats.
+const (
+	statsSuccess = iota
+	statsMissing
+	statsMalformed
+	statsInvalidSignature
+	statsExpired
+	statsInvalidAudience
+	statsInvalidIssuer
+	statsOther
+	statsCategories
+)
+
+// statsCategory returns the Stats category of the check's error.
+func statsCategory(err error) int {
+	var ve *jwt.ValidationError
+	switch {
+	case err == nil:
+		return statsSuccess
+	case errors.Is(err, errTokenNotFound) || errors.Is(err, ErrNoCredentials):
+		return statsMissing
+	case errors.Is(err, ErrTokenExpired):
+		return statsExpired
+	case errors.Is(err, ErrInvalidAudience):
+		return statsInvalidAudience
+	case errors.Is(err, ErrInvalidIssuer):
+		return statsInvalidIssuer
+	case errors.Is(err, ErrAssociatedDataMismatch):
+		return statsInvalidSignature
+	case errors.As(err, &ve) && ve.Errors&jwt.ValidationErrorMalformed != 0:
+		return statsMalformed
+	case errors.As(err, &ve) && ve.Errors&jwt.ValidationErrorSignatureInvalid != 0:
+		return statsInvalidSignature
+	default:
+		return statsOther
+	}
+}
+
+// countKeyID counts the accepted token by its 'kid' header, if it has one
+// and its signature was verified.
+func (m *Core) countKeyID(res *Result) {
+	kid, _ := res.Token.Header["kid"].(string)
+	if kid == "" || !res.Verified {
+		return
+	}
+
+	count, ok := m.kids.Load(kid)
+	if !ok {
+		count, _ = m.kids.LoadOrStore(kid, new(atomic.Int64))
+	}
+
+	count.(*atomic.Int64).Add(1)
+}
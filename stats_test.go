@@ -0,0 +1,69 @@
+package jaywt_test
+
+import (
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestStats(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:    sampleKeyfunc,
+		Audience:   "api",
+		Issuer:     "https://example.com",
+		TrackStats: true,
+	})
+	exp := float64(time.Now().Add(-1 * time.Hour).Unix())
+
+	reqs := []*http.Request{
+		sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"aud": "api", "iss": "https://example.com"}),
+		sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"aud": "api", "iss": "https://example.com"}),
+		httptest.NewRequest(http.MethodGet, "/", nil),
+		opaqueRequest("asdf"),
+		secretRequest(t, "someOtherSecret"),
+		sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"exp": exp}),
+		sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"aud": "web"}),
+		sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"aud": "api", "iss": "https://evil.example.com"}),
+		sampleRequest(t, jwt.SigningMethodHS384, jwt.MapClaims{"aud": "api", "iss": "https://example.com"}),
+	}
+
+	var wg sync.WaitGroup
+	for _, req := range reqs {
+		wg.Add(1)
+		go func(req *http.Request) {
+			defer wg.Done()
+			p.Get(req)
+		}(req)
+	}
+	wg.Wait()
+
+	want := jaywt.Stats{
+		Successes:        2,
+		Missing:          1,
+		Malformed:        1,
+		InvalidSignature: 1,
+		Expired:          1,
+		InvalidAudience:  1,
+		InvalidIssuer:    1,
+		Other:            1,
+	}
+	if got := p.Stats(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Got %+v, want %+v", got, want)
+	}
+}
+
+func TestStatsOff(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	p.Get(sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject}))
+	if got := p.Stats(); !reflect.DeepEqual(got, jaywt.Stats{}) {
+		t.Errorf("Got %+v, want no stats", got)
+	}
+}
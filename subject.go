@@ -0,0 +1,34 @@
+package jaywt
+
+import (
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+)
+
+// GetWithSubjectMatch extracts and validates the JWT token from the request,
+// then checks that its 'sub' claim is expectedSub, e.g. the user ID from
+// a '/users/{id}' route, so users can't access each other's resources.
+// An empty expectedSub never matches. It returns the parsed token,
+// if successful.
+func (m *Core) GetWithSubjectMatch(r *http.Request, expectedSub string) (*jwt.Token, error) {
+	return m.getVerified(r, jwt.MapClaims{}, ValidatorFunc(func(token *jwt.Token, _ *http.Request) error {
+		return checkSubject(token, expectedSub)
+	}))
+}
+
+// Helper functions
+// ---
+
+// checkSubject checks that the token's 'sub' claim is expectedSub.
+func checkSubject(token *jwt.Token, expectedSub string) error {
+	claims, err := claimsMap(token)
+	if err != nil {
+		return err
+	}
+
+	if sub, _ := claims["sub"].(string); expectedSub == "" || sub != expectedSub {
+		return ErrSubjectMismatch
+	}
+
+	return nil
+}
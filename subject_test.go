@@ -0,0 +1,32 @@
+package jaywt_test
+
+import (
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"testing"
+)
+
+var subjectMatchTable = []struct {
+	claims jwt.MapClaims
+	sub    string
+	err    error
+}{
+	{jwt.MapClaims{"sub": sampleSubject}, sampleSubject, nil},
+	{jwt.MapClaims{"sub": sampleSubject}, "auth0|someoneElse", jaywt.ErrSubjectMismatch},
+	{jwt.MapClaims{"sub": sampleSubject}, "", jaywt.ErrSubjectMismatch},
+	{jwt.MapClaims{"iss": "https://example.com"}, sampleSubject, jaywt.ErrSubjectMismatch},
+	{jwt.MapClaims{"iss": "https://example.com"}, "", jaywt.ErrSubjectMismatch},
+}
+
+func TestGetWithSubjectMatch(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+	})
+
+	for _, c := range subjectMatchTable {
+		_, err := p.GetWithSubjectMatch(sampleRequest(t, jwt.SigningMethodHS256, c.claims), c.sub)
+		if err != c.err {
+			t.Errorf("%v, %q: Got %v, want %v", c.claims, c.sub, err, c.err)
+		}
+	}
+}
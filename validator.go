@@ -0,0 +1,320 @@
+package jaywt
+
+import (
+	"encoding/hex"
+	"fmt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Validator is a stage of the validation pipeline, run by the checking
+// functions after the built-in checks. The request is nil when validating
+// with ValidateString.
+type Validator interface {
+	Validate(token *jwt.Token, r *http.Request) error
+}
+
+// ValidatorFunc is a function implementing Validator.
+type ValidatorFunc func(token *jwt.Token, r *http.Request) error
+
+// Validate calls f(token, r).
+func (f ValidatorFunc) Validate(token *jwt.Token, r *http.Request) error {
+	return f(token, r)
+}
+
+// AudienceValidator returns a Validator requiring the audience in the
+// token's 'aud' claim, like Options.Audience. It honors NormalizeURLClaims
+// of the Core running it.
+func AudienceValidator(aud string) Validator {
+	return optionsValidator(func(claims jwt.MapClaims, o *Options) error {
+		return checkAudience(claims, aud, o.NormalizeURLClaims)
+	})
+}
+
+// IssuerValidator returns a Validator requiring the token's 'iss' claim to
+// be the issuer, like Options.Issuer. It honors NormalizeURLClaims of the
+// Core running it.
+func IssuerValidator(iss string) Validator {
+	return optionsValidator(func(claims jwt.MapClaims, o *Options) error {
+		return checkIssuer(claims, iss, o.NormalizeURLClaims)
+	})
+}
+
+// ExpRequiredValidator returns a Validator rejecting tokens without the
+// 'exp' claim, like Options.TreatNoExpAsExpired.
+func ExpRequiredValidator() Validator {
+	return claimsValidator(checkExpPresent)
+}
+
+// MaxAgeValidator returns a Validator rejecting tokens issued longer than
+// maxAge ago, like Options.MaxAge.
+func MaxAgeValidator(maxAge time.Duration) Validator {
+	return claimsValidator(func(claims jwt.MapClaims) error {
+		return checkMaxAge(claims, maxAge)
+	})
+}
+
+// DisallowedAMRValidator returns a Validator rejecting tokens listing any of
+// the authentication methods in their 'amr' claim, like
+// Options.DisallowedAMR.
+func DisallowedAMRValidator(methods ...string) Validator {
+	return claimsValidator(func(claims jwt.MapClaims) error {
+		return checkAMR(claims, methods)
+	})
+}
+
+// FreshTokenRequired returns a Validator rejecting tokens issued longer than
+// window ago with ErrStaleToken, e.g. for sensitive routes making clients
+// refresh their tokens silently. Unlike MaxAgeValidator, the error says how
+// old the token is. Tokens without the 'iat' claim are stale.
+func FreshTokenRequired(window time.Duration) Validator {
+	return claimsValidator(func(claims jwt.MapClaims) error {
+		return checkFresh(claims, window)
+	})
+}
+
+// RequireVerifiedEmail returns a Validator rejecting tokens without a
+// non-empty 'email' claim whose 'email_verified' claim is true with
+// ErrEmailNotVerified, e.g. for an onboarding flow. Issuers sending
+// 'email_verified' as the string "true" are accepted, and the claim of
+// jwt.MapClaims is canonicalized to the boolean.
+func RequireVerifiedEmail() Validator {
+	return claimsValidator(checkVerifiedEmail)
+}
+
+// RequireUUIDJTI returns a Validator rejecting tokens whose 'jti' claim
+// isn't a UUID with ErrInvalidJTI, e.g. for revocation lists keyed by it.
+// Both the hyphenated and the plain 32 hex digit forms are accepted.
+func RequireUUIDJTI() Validator {
+	return claimsValidator(checkUUIDJTI)
+}
+
+// ClaimValidator returns a Validator checking the named claim with the
+// function, like Options.ClaimValidators.
+func ClaimValidator(name string, validate func(value interface{}) error) Validator {
+	return claimsValidator(func(claims jwt.MapClaims) error {
+		return checkClaim(claims, name, validate)
+	})
+}
+
+// Helper functions
+// ---
+
+// claimsValidator returns a Validator checking the token's claims.
+func claimsValidator(check func(claims jwt.MapClaims) error) Validator {
+	return ValidatorFunc(func(token *jwt.Token, _ *http.Request) error {
+		claims, err := claimsMap(token)
+		if err != nil {
+			return err
+		}
+
+		return check(claims)
+	})
+}
+
+// optionsValidator is a Validator checking the token's claims with the
+// options of the Core running it. Run on its own, it uses the defaults.
+type optionsValidator func(claims jwt.MapClaims, o *Options) error
+
+// Validate checks the token's claims with the default options.
+func (f optionsValidator) Validate(token *jwt.Token, _ *http.Request) error {
+	return f.validate(token, &Options{})
+}
+
+func (f optionsValidator) validate(token *jwt.Token, o *Options) error {
+	claims, err := claimsMap(token)
+	if err != nil {
+		return err
+	}
+
+	return f(claims, o)
+}
+
+// runValidator runs the validator, passing the options to those reading them.
+func (m *Core) runValidator(v Validator, token *jwt.Token, r *http.Request) error {
+	if f, ok := v.(optionsValidator); ok {
+		return f.validate(token, m.Options)
+	}
+
+	return v.Validate(token, r)
+}
+
+// checkAudience requires the audience in the 'aud' claim. If normalize is
+// set, URLs are compared with normalizeURL.
+func checkAudience(claims jwt.MapClaims, aud string, normalize bool) error {
+	if !normalize {
+		if !containsString(audiences(claims), aud) {
+			return ErrInvalidAudience
+		}
+
+		return nil
+	}
+
+	want := normalizeURL(aud)
+	for _, a := range audiences(claims) {
+		if normalizeURL(a) == want {
+			return nil
+		}
+	}
+
+	return ErrInvalidAudience
+}
+
+// checkForbiddenAudiences rejects the forbidden audiences in the 'aud'
+// claim. If normalize is set, URLs are compared with normalizeURL.
+func checkForbiddenAudiences(claims jwt.MapClaims, forbidden []string, normalize bool) error {
+	for _, aud := range forbidden {
+		if checkAudience(claims, aud, normalize) == nil {
+			return fmt.Errorf("%w: '%s'", ErrForbiddenAudience, aud)
+		}
+	}
+
+	return nil
+}
+
+// checkIssuer requires the issuer in the 'iss' claim. If normalize is set,
+// URLs are compared with normalizeURL.
+func checkIssuer(claims jwt.MapClaims, iss string, normalize bool) error {
+	if !normalize {
+		if claims["iss"] != iss {
+			return ErrInvalidIssuer
+		}
+
+		return nil
+	}
+
+	if got, ok := claims["iss"].(string); !ok || normalizeURL(got) != normalizeURL(iss) {
+		return ErrInvalidIssuer
+	}
+
+	return nil
+}
+
+// normalizeURL lowercases the scheme and host of absolute URLs, and trims
+// their trailing slashes. Other values are returned as they are.
+func normalizeURL(value string) string {
+	u, err := url.Parse(value)
+	if err != nil || u.Scheme == "" || u.Host == "" {
+		return value
+	}
+
+	u.Scheme = strings.ToLower(u.Scheme)
+	u.Host = strings.ToLower(u.Host)
+	return strings.TrimRight(u.String(), "/")
+}
+
+func checkExpPresent(claims jwt.MapClaims) error {
+	if _, ok := numericClaim(claims, "exp"); !ok {
+		return fmt.Errorf("%w: no 'exp' claim", ErrTokenExpired)
+	}
+
+	return nil
+}
+
+func checkMaxAge(claims jwt.MapClaims, maxAge time.Duration) error {
+	if iat, ok := numericClaim(claims, "iat"); !ok || time.Since(time.Unix(iat, 0)) > maxAge {
+		return ErrTokenTooOld
+	}
+
+	return nil
+}
+
+// checkAMR rejects the disallowed methods in the 'amr' claim, which may be
+// a single string or an array of them.
+func checkAMR(claims jwt.MapClaims, disallowed []string) error {
+	var methods []interface{}
+	switch amr := claims["amr"].(type) {
+	case string:
+		methods = []interface{}{amr}
+	case []interface{}:
+		methods = amr
+	}
+
+	for _, method := range methods {
+		if name, ok := method.(string); ok && containsString(disallowed, name) {
+			return fmt.Errorf("%w: '%s'", ErrDisallowedAMR, name)
+		}
+	}
+
+	return nil
+}
+
+func checkFresh(claims jwt.MapClaims, window time.Duration) error {
+	iat, ok := numericClaim(claims, "iat")
+	if !ok {
+		return fmt.Errorf("%w: no 'iat' claim, refresh the token", ErrStaleToken)
+	}
+
+	if age := time.Since(time.Unix(iat, 0)); age > window {
+		return fmt.Errorf("%w: issued %v ago, refresh the token to use one issued within %v", ErrStaleToken, age.Round(time.Second), window)
+	}
+
+	return nil
+}
+
+func checkVerifiedEmail(claims jwt.MapClaims) error {
+	if email, _ := claims["email"].(string); email == "" {
+		return fmt.Errorf("%w: no 'email' claim", ErrEmailNotVerified)
+	}
+
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		if verified {
+			return nil
+		}
+	case string:
+		if strings.EqualFold(verified, "true") {
+			claims["email_verified"] = true
+			return nil
+		}
+	}
+
+	return ErrEmailNotVerified
+}
+
+func checkUUIDJTI(claims jwt.MapClaims) error {
+	jti, ok := claims["jti"].(string)
+	if !ok {
+		return fmt.Errorf("%w: no 'jti' claim", ErrInvalidJTI)
+	}
+
+	if !isUUID(jti) {
+		return fmt.Errorf("%w: '%s'", ErrInvalidJTI, jti)
+	}
+
+	return nil
+}
+
+// isUUID reports whether s is a UUID, hyphenated or not.
+func isUUID(s string) bool {
+	switch len(s) {
+	case 36:
+		for _, i := range []int{8, 13, 18, 23} {
+			if s[i] != '-' {
+				return false
+			}
+		}
+
+		s = strings.ReplaceAll(s, "-", "")
+		if len(s) != 32 {
+			return false
+		}
+	case 32:
+	default:
+		return false
+	}
+
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+func checkClaim(claims jwt.MapClaims, name string, validate func(value interface{}) error) error {
+	if err := validate(claims[name]); err != nil {
+		return fmt.Errorf("%w: '%s': %w", ErrClaimInvalid, name, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,287 @@
+package jaywt_test
+
+import (
+	"errors"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestValidatorsOrder(t *testing.T) {
+	var order []string
+	stage := func(name string, err error) jaywt.Validator {
+		return jaywt.ValidatorFunc(func(_ *jwt.Token, _ *http.Request) error {
+			order = append(order, name)
+			return err
+		})
+	}
+
+	failed := errors.New("Stage failed")
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Validators: []jaywt.Validator{
+			stage("first", nil),
+			stage("second", failed),
+			stage("third", nil),
+		},
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+	if _, err := p.Get(req); err != failed {
+		t.Errorf("Got %v, want %v", err, failed)
+	}
+
+	if len(order) != 2 || order[0] != "first" || order[1] != "second" {
+		t.Errorf("Got %v, want [first second]", order)
+	}
+}
+
+func TestDryRunValidators(t *testing.T) {
+	var observed []error
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		DryRunValidators: []jaywt.Validator{
+			jaywt.AudienceValidator("api"),
+			jaywt.IssuerValidator("https://example.com"),
+			jaywt.ExpRequiredValidator(),
+		},
+		OnValidate: func(_ *http.Request, res *jaywt.Result, _ error) {
+			observed = res.DryRunErrors
+		},
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"aud": "web", "iss": "https://example.com"})
+	res, err := p.GetResult(req, jwt.MapClaims{})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []error{jaywt.ErrInvalidAudience, jaywt.ErrTokenExpired}
+	if len(res.DryRunErrors) != len(want) || res.DryRunErrors[0] != want[0] || !errors.Is(res.DryRunErrors[1], want[1]) {
+		t.Errorf("Got %v, want %v", res.DryRunErrors, want)
+	}
+
+	if len(observed) != len(want) {
+		t.Errorf("OnValidate: Got %v, want %v", observed, want)
+	}
+}
+
+func TestValidatorsRequest(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Validators: []jaywt.Validator{
+			jaywt.ValidatorFunc(func(_ *jwt.Token, r *http.Request) error {
+				if r.Header.Get("X-Tenant") != "acme" {
+					return errors.New("Wrong tenant")
+				}
+
+				return nil
+			}),
+		},
+	})
+
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+	if _, err := p.Get(req); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+
+	req.Header.Set("X-Tenant", "acme")
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+	}
+}
+
+var builtinValidatorsTable = []struct {
+	validator jaywt.Validator
+	good      jwt.MapClaims
+	bad       jwt.MapClaims
+	err       error
+}{
+	{
+		jaywt.AudienceValidator("api"),
+		jwt.MapClaims{"aud": []interface{}{"web", "api"}},
+		jwt.MapClaims{"aud": "web"},
+		jaywt.ErrInvalidAudience,
+	},
+	{
+		jaywt.IssuerValidator("https://example.com"),
+		jwt.MapClaims{"iss": "https://example.com"},
+		jwt.MapClaims{"iss": "https://evil.example.com"},
+		jaywt.ErrInvalidIssuer,
+	},
+	{
+		jaywt.ExpRequiredValidator(),
+		jwt.MapClaims{"exp": float64(time.Now().Add(1 * time.Hour).Unix())},
+		jwt.MapClaims{},
+		jaywt.ErrTokenExpired,
+	},
+	{
+		jaywt.MaxAgeValidator(5 * time.Minute),
+		jwt.MapClaims{"iat": float64(time.Now().Unix())},
+		jwt.MapClaims{"iat": float64(time.Now().Add(-10 * time.Minute).Unix())},
+		jaywt.ErrTokenTooOld,
+	},
+}
+
+func TestBuiltinValidatorsNormalizeURLClaims(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc: sampleKeyfunc,
+		Validators: []jaywt.Validator{
+			jaywt.AudienceValidator("https://api.example.com"),
+			jaywt.IssuerValidator("https://idp.example.com"),
+		},
+	})
+	req := sampleRequest(t, jwt.SigningMethodHS256, jwt.MapClaims{
+		"aud": "https://API.example.com/",
+		"iss": "https://IdP.example.com/",
+	})
+
+	// The validators compare like Options.Audience and Options.Issuer
+	if _, err := p.Get(req); err != jaywt.ErrInvalidAudience {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrInvalidAudience)
+	}
+
+	p.Options.NormalizeURLClaims = true
+	if _, err := p.Get(req); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestBuiltinValidators(t *testing.T) {
+	for _, c := range builtinValidatorsTable {
+		if err := c.validator.Validate(&jwt.Token{Claims: c.good}, nil); err != nil {
+			t.Errorf("%v: %v", c.good, err)
+		}
+
+		if err := c.validator.Validate(&jwt.Token{Claims: c.bad}, nil); !errors.Is(err, c.err) {
+			t.Errorf("%v: Got %v, want %v", c.bad, err, c.err)
+		}
+	}
+}
+
+func TestClaimValidator(t *testing.T) {
+	v := jaywt.ClaimValidator("tenant_id", claimValidators["tenant_id"])
+
+	if err := v.Validate(&jwt.Token{Claims: jwt.MapClaims{"tenant_id": "acme"}}, nil); err != nil {
+		t.Error(err)
+	}
+
+	if err := v.Validate(&jwt.Token{Claims: jwt.MapClaims{}}, nil); !errors.Is(err, jaywt.ErrClaimInvalid) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrClaimInvalid)
+	}
+}
+
+var freshTokenTableBad = []jwt.MapClaims{
+	{"iat": float64(time.Now().Add(-10 * time.Minute).Unix())},
+	{"sub": sampleSubject},
+}
+
+func TestFreshTokenRequired(t *testing.T) {
+	v := jaywt.FreshTokenRequired(5 * time.Minute)
+
+	if err := v.Validate(&jwt.Token{Claims: jwt.MapClaims{"iat": float64(time.Now().Add(-1 * time.Minute).Unix())}}, nil); err != nil {
+		t.Error(err)
+	}
+
+	for _, claims := range freshTokenTableBad {
+		if err := v.Validate(&jwt.Token{Claims: claims}, nil); !errors.Is(err, jaywt.ErrStaleToken) {
+			t.Errorf("%v: Got %v, want %v", claims, err, jaywt.ErrStaleToken)
+		}
+	}
+}
+
+var disallowedAMRTable = []struct {
+	claims  jwt.MapClaims
+	allowed bool
+}{
+	{jwt.MapClaims{"amr": []interface{}{"pwd", "otp"}}, true},
+	{jwt.MapClaims{"amr": "pwd"}, true},
+	{jwt.MapClaims{"sub": sampleSubject}, true},
+	{jwt.MapClaims{"amr": []interface{}{"pwd", "sms"}}, false},
+	{jwt.MapClaims{"amr": "sms"}, false},
+}
+
+func TestDisallowedAMR(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:       sampleKeyfunc,
+		DisallowedAMR: []string{"sms"},
+	})
+	v := jaywt.DisallowedAMRValidator("sms")
+
+	for _, c := range disallowedAMRTable {
+		_, err := p.Get(sampleRequest(t, jwt.SigningMethodHS256, c.claims))
+		if c.allowed && err != nil || !c.allowed && !errors.Is(err, jaywt.ErrDisallowedAMR) {
+			t.Errorf("%v: Got %v, want allowed %t", c.claims, err, c.allowed)
+		}
+
+		err = v.Validate(&jwt.Token{Claims: c.claims}, nil)
+		if c.allowed && err != nil || !c.allowed && !errors.Is(err, jaywt.ErrDisallowedAMR) {
+			t.Errorf("Validator: %v: Got %v, want allowed %t", c.claims, err, c.allowed)
+		}
+	}
+}
+
+var verifiedEmailTable = []struct {
+	claims   jwt.MapClaims
+	verified bool
+}{
+	{jwt.MapClaims{"email": "user@example.com", "email_verified": true}, true},
+	{jwt.MapClaims{"email": "user@example.com", "email_verified": "true"}, true},
+	{jwt.MapClaims{"email": "user@example.com", "email_verified": "True"}, true},
+	{jwt.MapClaims{"email": "user@example.com", "email_verified": false}, false},
+	{jwt.MapClaims{"email": "user@example.com", "email_verified": "false"}, false},
+	{jwt.MapClaims{"email": "user@example.com"}, false},
+	{jwt.MapClaims{"email": "", "email_verified": true}, false},
+	{jwt.MapClaims{"email_verified": true}, false},
+}
+
+func TestRequireVerifiedEmail(t *testing.T) {
+	v := jaywt.RequireVerifiedEmail()
+
+	for _, c := range verifiedEmailTable {
+		err := v.Validate(&jwt.Token{Claims: c.claims}, nil)
+		if c.verified && err != nil || !c.verified && !errors.Is(err, jaywt.ErrEmailNotVerified) {
+			t.Errorf("%v: Got %v, want verified %t", c.claims, err, c.verified)
+		}
+
+		if c.verified && c.claims["email_verified"] != true {
+			t.Errorf("%v: email_verified should be canonicalized to true", c.claims)
+		}
+	}
+}
+
+var uuidJTITable = []struct {
+	jti   interface{}
+	valid bool
+}{
+	{"f47ac10b-58cc-4372-a567-0e02b2c3d479", true},
+	{"F47AC10B-58CC-4372-A567-0E02B2C3D479", true},
+	{"f47ac10b58cc4372a5670e02b2c3d479", true},
+	{"f47ac10b-58cc-4372-a567-0e02b2c3d47", false},
+	{"f47ac10b-58cc-4372-a567_0e02b2c3d479", false},
+	{"f47ac10b-58cc-4372-a567-0e02b2c3d47z", false},
+	{"f47ac10b58cc-4372-a567-0e02b2c3d4790", false},
+	{"{f47ac10b-58cc-4372-a567-0e02b2c3d479}", false},
+	{"token-1", false},
+	{"", false},
+	{42, false},
+	{nil, false},
+}
+
+func TestRequireUUIDJTI(t *testing.T) {
+	v := jaywt.RequireUUIDJTI()
+
+	for _, c := range uuidJTITable {
+		claims := jwt.MapClaims{"sub": sampleSubject}
+		if c.jti != nil {
+			claims["jti"] = c.jti
+		}
+
+		err := v.Validate(&jwt.Token{Claims: claims}, nil)
+		if c.valid && err != nil || !c.valid && !errors.Is(err, jaywt.ErrInvalidJTI) {
+			t.Errorf("%v: Got %v, want valid %t", c.jti, err, c.valid)
+		}
+	}
+}
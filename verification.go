@@ -0,0 +1,143 @@
+package jaywt
+
+import (
+	"container/list"
+	"crypto/sha256"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"sync"
+	"time"
+)
+
+// ValidationCache remembers the tokens whose signatures were verified, so
+// their repeat presentations skip the verification. Entries are kept for the
+// TTL, but never past the token's 'exp' claim, and the least recently used
+// ones are evicted beyond the size. It is safe for concurrent use.
+//
+// Cached tokens skip the Keyfunc, so a rotated key keeps verifying them until
+// their entries expire. Keep the TTL short.
+//
+// A cache can be shared by several Cores, e.g. to bound the memory of all of
+// them. Each Core only sees the tokens it verified itself, as they may use
+// other keys.
+type ValidationCache struct {
+	size int
+	ttl  time.Duration
+
+	mu      sync.Mutex
+	order   *list.List
+	entries map[validationKey]*list.Element
+}
+
+// NewValidationCache returns a ValidationCache holding up to size tokens for
+// the given TTL.
+func NewValidationCache(size int, ttl time.Duration) *ValidationCache {
+	return &ValidationCache{
+		size:    size,
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[validationKey]*list.Element),
+	}
+}
+
+// Len returns the number of cached tokens, including expired ones not yet
+// evicted.
+func (c *ValidationCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.order.Len()
+}
+
+// Helper functions
+// ---
+
+// validationKey identifies a token verified by a Core.
+type validationKey struct {
+	core uint64
+	hash [sha256.Size]byte
+}
+
+type validation struct {
+	key     validationKey
+	expires time.Time
+}
+
+// verified reports whether the token's signature is cached as verified by
+// the Core. It is false for a nil cache.
+func (c *ValidationCache) verified(core uint64, raw string) bool {
+	if c == nil {
+		return false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := validationKey{core: core, hash: sha256.Sum256([]byte(raw))}
+	elem, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+
+	if time.Now().After(elem.Value.(*validation).expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return false
+	}
+
+	c.order.MoveToFront(elem)
+	return true
+}
+
+// add caches the token's signature as verified by the Core, until the TTL
+// passes or the token expires. It does nothing for a nil cache.
+func (c *ValidationCache) add(core uint64, raw string, token *jwt.Token) {
+	if c == nil || c.size <= 0 {
+		return
+	}
+
+	expires := time.Now().Add(c.ttl)
+	if exp, ok := expiresAt(token); ok && exp.Before(expires) {
+		expires = exp
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := validationKey{core: core, hash: sha256.Sum256([]byte(raw))}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value.(*validation).expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&validation{key: key, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*validation).key)
+	}
+}
+
+// parseCached parses the token whose signature is cached as verified, still
+// rejecting revoked keys and invalid time claims.
+func (m *Core) parseCached(raw string, claims jwt.Claims) (*jwt.Token, error) {
+	token, _, err := m.parser.ParseUnverified(raw, claims)
+	if err != nil {
+		return nil, err
+	}
+
+	kid, _ := token.Header["kid"].(string)
+	m.mu.RLock()
+	revoked := m.revoked[kid]
+	m.mu.RUnlock()
+	if revoked {
+		return nil, &jwt.ValidationError{Inner: ErrRevokedKID, Errors: jwt.ValidationErrorUnverifiable}
+	}
+
+	if err = token.Claims.Valid(); err != nil {
+		return token, err
+	}
+
+	token.Valid = true
+	return token, nil
+}
@@ -0,0 +1,205 @@
+package jaywt_test
+
+import (
+	"errors"
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestValidationCache(t *testing.T) {
+	var selected atomic.Int64
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:         countingKeyfunc(&selected),
+		Audience:        "api",
+		ValidationCache: jaywt.NewValidationCache(10, time.Hour),
+	})
+
+	raw := sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject, "aud": "api"})
+	for i := 0; i < 3; i++ {
+		if _, err := p.ValidateRaw(raw); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := selected.Load(); n != 1 {
+		t.Errorf("Got %d key selections, want 1", n)
+	}
+
+	// Claims are still validated on each check
+	p.Options.Audience = "web"
+	if _, err := p.ValidateRaw(raw); err != jaywt.ErrInvalidAudience {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrInvalidAudience)
+	}
+}
+
+func TestValidationCacheBadSignature(t *testing.T) {
+	var selected atomic.Int64
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:         countingKeyfunc(&selected),
+		ValidationCache: jaywt.NewValidationCache(10, time.Hour),
+	})
+
+	raw := sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject}, withKey([]byte("someOtherSecret")))
+	for i := 0; i < 2; i++ {
+		if _, err := p.ValidateRaw(raw); err == nil {
+			t.Error("Error was expected, got nil")
+		}
+	}
+
+	if n := p.Options.ValidationCache.Len(); n != 0 {
+		t.Errorf("Got %d cached tokens, want 0", n)
+	}
+}
+
+func TestValidationCacheTTL(t *testing.T) {
+	var selected atomic.Int64
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:         countingKeyfunc(&selected),
+		ValidationCache: jaywt.NewValidationCache(10, 10*time.Millisecond),
+	})
+
+	raw := sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+	if _, err := p.ValidateRaw(raw); err != nil {
+		t.Fatal(err)
+	}
+
+	time.Sleep(20 * time.Millisecond)
+	if _, err := p.ValidateRaw(raw); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := selected.Load(); n != 2 {
+		t.Errorf("Got %d key selections, want 2", n)
+	}
+}
+
+func TestValidationCacheExpired(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:         sampleKeyfunc,
+		ValidationCache: jaywt.NewValidationCache(10, time.Hour),
+	})
+
+	exp := time.Now().Add(time.Second).Unix()
+	raw := sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject, "exp": exp})
+	if _, err := p.ValidateRaw(raw); err != nil {
+		t.Fatal(err)
+	}
+
+	// Tokens are valid during the second of their 'exp' claim
+	time.Sleep(time.Until(time.Unix(exp+1, 0)))
+	if _, err := p.ValidateRaw(raw); !errors.Is(err, jaywt.ErrTokenExpired) {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrTokenExpired)
+	}
+}
+
+func TestValidationCacheEviction(t *testing.T) {
+	var selected atomic.Int64
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:         countingKeyfunc(&selected),
+		ValidationCache: jaywt.NewValidationCache(2, time.Hour),
+	})
+
+	first := sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": "first"})
+	for _, sub := range []string{"first", "second", "third"} {
+		if _, err := p.ValidateRaw(sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sub})); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if n := p.Options.ValidationCache.Len(); n != 2 {
+		t.Errorf("Got %d cached tokens, want 2", n)
+	}
+
+	// The least recently used token was evicted
+	if _, err := p.ValidateRaw(first); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := selected.Load(); n != 4 {
+		t.Errorf("Got %d key selections, want 4", n)
+	}
+}
+
+func TestValidationCacheRevokedKID(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:         sampleKeyfunc,
+		ValidationCache: jaywt.NewValidationCache(10, time.Hour),
+	})
+
+	raw := sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject}, withHeader("kid", "key-1"))
+	if _, err := p.ValidateRaw(raw); err != nil {
+		t.Fatal(err)
+	}
+
+	p.SetRevokedKIDs([]string{"key-1"})
+	if _, err := p.ValidateRaw(raw); err != jaywt.ErrRevokedKID {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrRevokedKID)
+	}
+}
+
+func TestValidationCacheConcurrent(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:         sampleKeyfunc,
+		ValidationCache: jaywt.NewValidationCache(4, time.Hour),
+	})
+
+	raws := make([]string, 8)
+	for i := range raws {
+		raws[i] = sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject, "n": i})
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 50; j++ {
+				if _, err := p.ValidateRaw(raws[j%len(raws)]); err != nil {
+					t.Error(err)
+					return
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+func TestValidationCacheShared(t *testing.T) {
+	cache := jaywt.NewValidationCache(10, time.Hour)
+	trusting := jaywt.New(&jaywt.Options{
+		Keyfunc:         sampleKeyfunc,
+		ValidationCache: cache,
+	})
+	strict := jaywt.New(&jaywt.Options{
+		Keyfunc: func(_ *jwt.Token) (interface{}, error) {
+			return []byte("someOtherSecret"), nil
+		},
+		ValidationCache: cache,
+	})
+
+	raw := sampleRaw(t, jwt.SigningMethodHS256, jwt.MapClaims{"sub": sampleSubject})
+	if _, err := trusting.ValidateRaw(raw); err != nil {
+		t.Fatal(err)
+	}
+
+	// The other Core still verifies the token with its own key
+	if _, err := strict.ValidateRaw(raw); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+// Helper functions
+// ---
+
+// countingKeyfunc serves sampleSecret, counting the calls.
+func countingKeyfunc(calls *atomic.Int64) jwt.Keyfunc {
+	return func(token *jwt.Token) (interface{}, error) {
+		calls.Add(1)
+		return sampleKeyfunc(token)
+	}
+}
@@ -0,0 +1,22 @@
+package jaywt
+
+import (
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"time"
+)
+
+// WatchExpiry calls onExpire in its own goroutine once the token expires,
+// with Options.Leeway added, e.g. to close a long-lived stream authorized by
+// it. Tokens without the 'exp' claim never expire. Call the returned stop
+// function when the stream ends, so onExpire isn't called anymore.
+func (m *Core) WatchExpiry(token *jwt.Token, onExpire func()) (stop func()) {
+	exp, ok := expiresAt(token)
+	if !ok {
+		return func() {}
+	}
+
+	timer := time.AfterFunc(time.Until(exp.Add(m.Options.Leeway)), onExpire)
+	return func() {
+		timer.Stop()
+	}
+}
@@ -0,0 +1,53 @@
+package jaywt_test
+
+import (
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"testing"
+	"time"
+)
+
+func TestWatchExpiryFires(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{})
+	token := &jwt.Token{Claims: jwt.MapClaims{"exp": float64(time.Now().Add(1 * time.Second).Unix())}}
+
+	expired := make(chan struct{})
+	stop := p.WatchExpiry(token, func() { close(expired) })
+	defer stop()
+
+	select {
+	case <-expired:
+	case <-time.After(3 * time.Second):
+		t.Error("onExpire was not called")
+	}
+}
+
+func TestWatchExpiryStop(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{})
+	token := &jwt.Token{Claims: jwt.MapClaims{"exp": float64(time.Now().Add(1 * time.Second).Unix())}}
+
+	expired := make(chan struct{})
+	stop := p.WatchExpiry(token, func() { close(expired) })
+	stop()
+
+	select {
+	case <-expired:
+		t.Error("onExpire should not be called after stop")
+	case <-time.After(2 * time.Second):
+	}
+}
+
+func TestWatchExpiryNoExp(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{})
+	token := &jwt.Token{Claims: jwt.MapClaims{"sub": sampleSubject}}
+
+	expired := make(chan struct{})
+	stop := p.WatchExpiry(token, func() { close(expired) })
+	defer stop()
+
+	select {
+	case <-expired:
+		t.Error("onExpire should not be called for tokens without 'exp'")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
@@ -0,0 +1,52 @@
+package jaywt
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"io"
+	"net/http"
+)
+
+// webhookMaxBytes is the maximum size of a webhook body, unless
+// Options.MaxClaimsBytes is set.
+const webhookMaxBytes = 1 << 20
+
+// VerifyWebhook extracts the detached JWS (RFC 7515, appendix F) signing the
+// request's body, e.g. of a webhook from a provider sending the signature in
+// a header, and validates it with the body as its payload. The body must thus
+// be the JSON claims, and is restored for the next handler. Bodies larger
+// than Options.MaxClaimsBytes, or 1 MiB if it isn't set, are rejected with
+// ErrClaimsTooLarge before being verified. It returns the parsed token,
+// if successful.
+func (m *Core) VerifyWebhook(r *http.Request) (*jwt.Token, error) {
+	body := []byte{}
+	if r.Body != nil {
+		var err error
+		limit := int64(webhookMaxBytes)
+		if m.Options.MaxClaimsBytes > 0 {
+			limit = int64(m.Options.MaxClaimsBytes)
+		}
+
+		body, err = io.ReadAll(io.LimitReader(r.Body, limit+1))
+		r.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("Error reading webhook body: %w", err)
+		}
+
+		if int64(len(body)) > limit {
+			return nil, ErrClaimsTooLarge
+		}
+	}
+
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	return m.getVerified(r.WithContext(context.WithValue(r.Context(), webhookBodyKey{}, body)), jwt.MapClaims{})
+}
+
+// Helper functions
+// ---
+
+// webhookBodyKey is the context key of the body VerifyWebhook attaches as
+// the detached payload.
+type webhookBodyKey struct{}
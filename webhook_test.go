@@ -0,0 +1,113 @@
+package jaywt_test
+
+import (
+	"github.com/oreqizer/go-jaywt"
+	"gopkg.in/dgrijalva/jwt-go.v3"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+const sampleWebhookBody = `{"id": "evt_1", "event": "order.paid"}`
+
+func TestVerifyWebhook(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:   sampleKeyfunc,
+		Extractor: fromSignatureHeader,
+	})
+
+	req := webhookRequest(t, sampleWebhookBody, sampleWebhookBody, true)
+	token, err := p.VerifyWebhook(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if event := token.Claims.(jwt.MapClaims)["event"]; event != "order.paid" {
+		t.Errorf("Got %v, want order.paid", event)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(body) != sampleWebhookBody {
+		t.Errorf("Got %s, want %s", body, sampleWebhookBody)
+	}
+}
+
+func TestVerifyWebhookTampered(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:   sampleKeyfunc,
+		Extractor: fromSignatureHeader,
+	})
+
+	req := webhookRequest(t, sampleWebhookBody, `{"event":"order.refunded","id":"evt_1"}`, true)
+	if _, err := p.VerifyWebhook(req); err == nil {
+		t.Error("Error was expected, got nil")
+	}
+}
+
+func TestVerifyWebhookTooLarge(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:        sampleKeyfunc,
+		Extractor:      fromSignatureHeader,
+		MaxClaimsBytes: len(sampleWebhookBody),
+	})
+
+	if _, err := p.VerifyWebhook(webhookRequest(t, sampleWebhookBody, sampleWebhookBody, true)); err != nil {
+		t.Error(err)
+	}
+
+	p.Options.MaxClaimsBytes = len(sampleWebhookBody) - 1
+	if _, err := p.VerifyWebhook(webhookRequest(t, sampleWebhookBody, sampleWebhookBody, true)); err != jaywt.ErrClaimsTooLarge {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrClaimsTooLarge)
+	}
+
+	// Without MaxClaimsBytes, bodies are limited to 1 MiB
+	p.Options.MaxClaimsBytes = 0
+	body := `{"pad":"` + strings.Repeat("a", 1<<20) + `"}`
+	if _, err := p.VerifyWebhook(webhookRequest(t, body, body, true)); err != jaywt.ErrClaimsTooLarge {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrClaimsTooLarge)
+	}
+}
+
+func TestVerifyWebhookNotDetached(t *testing.T) {
+	p := jaywt.New(&jaywt.Options{
+		Keyfunc:   sampleKeyfunc,
+		Extractor: fromSignatureHeader,
+	})
+
+	req := webhookRequest(t, `{"event":"order.refunded"}`, sampleWebhookBody, false)
+	if _, err := p.VerifyWebhook(req); err != jaywt.ErrNotDetached {
+		t.Errorf("Got %v, want %v", err, jaywt.ErrNotDetached)
+	}
+}
+
+// Helper functions
+// ---
+
+func fromSignatureHeader(r *http.Request) (string, error) {
+	return r.Header.Get("X-Signature"), nil
+}
+
+// webhookRequest posts the body, with the signature of the signed payload in
+// the X-Signature header. The payload is removed from it if detached.
+func webhookRequest(t *testing.T, signed, body string, detached bool) *http.Request {
+	header := jwt.EncodeSegment([]byte(`{"alg":"HS256","typ":"JWT"}`))
+	payload := jwt.EncodeSegment([]byte(signed))
+	sig, err := jwt.SigningMethodHS256.Sign(header+"."+payload, []byte(sampleSecret))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if detached {
+		payload = ""
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/webhooks", strings.NewReader(body))
+	req.Header.Set("X-Signature", header+"."+payload+"."+sig)
+	return req
+}